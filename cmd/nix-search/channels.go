@@ -0,0 +1,92 @@
+//nolint:gochecknoglobals
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+	"github.com/peterldowns/nix-search-cli/pkg/render"
+)
+
+var channelsCommand = &cobra.Command{
+	Use:   "channels [flags]",
+	Short: "list the channels that can be searched",
+	Example: CLIExample(`
+# List the channels that can be passed to --channel
+nix-search channels
+# ... or the channels that have been downloaded with "nix-search sync"
+nix-search channels --offline
+	`),
+	Args: cobra.NoArgs,
+	RunE: channels,
+}
+
+func channels(c *cobra.Command, _ []string) error {
+	if err := checkJSONLines(c.Name()); err != nil {
+		return err
+	}
+	client, err := newClient(c)
+	if err != nil {
+		return err
+	}
+	lister, ok := client.(nixsearch.ChannelLister)
+	if !ok {
+		return fmt.Errorf("%T can not list channels", client)
+	}
+	channels, err := lister.ListChannels(context.Background())
+	if err != nil {
+		return err
+	}
+
+	shouldOutputJSON := jsonOutput()
+	width := 0
+	for _, channel := range channels {
+		width = max(width, len(channel.Name))
+	}
+	for _, channel := range channels {
+		if shouldOutputJSON {
+			bytes, _ := json.Marshal(channel)
+			fmt.Println(string(bytes))
+			continue
+		}
+		// unstable  120,001 packages  latest-43-nixos-unstable
+		fmt.Printf(
+			"%-*s  %s  %s\n",
+			width,
+			channel.Name,
+			formatVersion(render.Count(channel.Packages)+" packages"),
+			color.New(color.Faint).Sprint(channel.Index),
+		)
+	}
+	return nil
+}
+
+// checkChannel resolves a symbolic channel name like "stable" and returns
+// an error, with a suggestion, if the client knows which channels exist and
+// the channel isn't one of them. It's called before searching, so that a
+// misspelled channel is reported as such rather than finding nothing. If the
+// channels can't be listed, the search itself will report any problem with a
+// concrete channel name, so that is only treated as an error for symbolic
+// names.
+func checkChannel(ctx context.Context, client nixsearch.Client, channel string) (string, error) {
+	lister, ok := client.(nixsearch.ChannelLister)
+	if !ok {
+		return channel, nil
+	}
+	channels, err := lister.ListChannels(ctx)
+	if err != nil && nixsearch.IsSymbolicChannel(channel) {
+		return "", err
+	}
+	if err != nil || len(channels) == 0 {
+		return channel, nil
+	}
+	if err := nixsearch.ValidateChannel(channels, channel); err != nil {
+		return "", err
+	}
+	return nixsearch.ResolveChannel(channels, channel)
+}
@@ -0,0 +1,155 @@
+//nolint:gochecknoglobals
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+)
+
+var compareCommand = &cobra.Command{
+	Use:   "compare attr... [flags]",
+	Short: "compare the versions of packages across channels",
+	Example: CLIExample(`
+# Show the version of each package in unstable and the two newest
+# releases, or "missing" if a channel doesn't have it
+nix-search compare go python3 nodejs
+# ... in specific channels
+nix-search compare go --channels unstable,24.05,23.11
+# ... as json, one line per package
+nix-search compare go python3 --json
+	`),
+	Args: cobra.MinimumNArgs(1),
+	RunE: compare,
+}
+
+var compareFlags struct {
+	Channels *[]string
+	Workers  *int
+}
+
+func compare(c *cobra.Command, args []string) error {
+	if err := checkJSONLines(c.Name()); err != nil {
+		return err
+	}
+	if *rootFlags.Flakes {
+		return errors.New("compare only works with channels, not --flakes")
+	}
+	if *compareFlags.Workers < 1 {
+		return fmt.Errorf("--workers must be at least 1, got %d", *compareFlags.Workers)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client, err := newClient(c)
+	if err != nil {
+		return err
+	}
+	// Symbolic names like "stable" are resolved once, up front, so that
+	// every column is labelled with the release it shows.
+	var channels []string
+	for _, name := range *compareFlags.Channels {
+		channel, err := checkChannel(ctx, client, strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(channels, channel) {
+			channels = append(channels, channel)
+		}
+	}
+
+	matrix, err := nixsearch.CompareChannels(ctx, client, args, channels, *compareFlags.Workers)
+	if err != nil {
+		return err
+	}
+	return printVersionMatrix(os.Stdout, matrix, jsonOutput())
+}
+
+// jsonVersions is how each package is printed by compare with --json: its
+// version in every channel, or null if the channel doesn't have it.
+type jsonVersions struct {
+	AttrName string             `json:"attr_name"`
+	Versions map[string]*string `json:"versions"`
+}
+
+// printVersionMatrix writes a table of the version of each package in each
+// channel to w, or a line of JSON for each package if asJSON is set.
+func printVersionMatrix(w io.Writer, matrix nixsearch.VersionMatrix, asJSON bool) error {
+	var out strings.Builder
+	if asJSON {
+		for _, attrName := range matrix.AttrNames {
+			versions := jsonVersions{AttrName: attrName, Versions: map[string]*string{}}
+			for _, channel := range matrix.Channels {
+				if version, ok := matrix.Version(attrName, channel); ok {
+					versions.Versions[channel] = &version
+				} else {
+					versions.Versions[channel] = nil
+				}
+			}
+			bytes, _ := json.Marshal(versions)
+			out.Write(bytes)
+			out.WriteString("\n")
+		}
+		_, err := io.WriteString(w, out.String())
+		return err
+	}
+
+	// attr     unstable  24.05
+	// go       1.22.5    1.22.3
+	// go_1_23  1.23.0    missing
+	rows := [][]string{slices.Concat([]string{"attr"}, matrix.Channels)}
+	for _, attrName := range matrix.AttrNames {
+		row := []string{attrName}
+		for _, channel := range matrix.Channels {
+			version, ok := matrix.Version(attrName, channel)
+			if !ok {
+				version = "missing"
+			}
+			row = append(row, version)
+		}
+		rows = append(rows, row)
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	for r, row := range rows {
+		for i, cell := range row {
+			// Pad before styling, so that escape codes don't count towards
+			// the width of the column.
+			padded := cell + strings.Repeat(" ", widths[i]-len(cell))
+			if i == len(row)-1 {
+				padded = cell
+			}
+			switch {
+			case r == 0:
+				padded = color.New(color.Bold).Sprint(padded)
+			case i == 0:
+				padded = color.New(color.FgBlue).Sprint(padded)
+			case cell == "missing":
+				padded = color.New(color.FgRed, color.Faint).Sprint(padded)
+			default:
+				padded = formatVersion(padded)
+			}
+			if i != 0 {
+				out.WriteString("  ")
+			}
+			out.WriteString(padded)
+		}
+		out.WriteString("\n")
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
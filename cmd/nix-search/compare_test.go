@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+)
+
+func testVersionMatrix() nixsearch.VersionMatrix {
+	return nixsearch.VersionMatrix{
+		AttrNames: []string{"go", "go_1_23"},
+		Channels:  []string{"unstable", "24.05"},
+		Versions: map[string]map[string]string{
+			"go":      {"unstable": "1.22.5", "24.05": "1.22.3"},
+			"go_1_23": {"unstable": "1.23.0"},
+		},
+	}
+}
+
+func TestPrintVersionMatrix(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	assert.NoError(t, printVersionMatrix(&out, testVersionMatrix(), false))
+	check.Equal(t, ""+
+		"attr     unstable  24.05\n"+
+		"go       1.22.5    1.22.3\n"+
+		"go_1_23  1.23.0    missing\n",
+		out.String(),
+	)
+}
+
+func TestPrintVersionMatrixJSON(t *testing.T) {
+	t.Parallel()
+
+	// Channels that don't have the package are null.
+	var out bytes.Buffer
+	assert.NoError(t, printVersionMatrix(&out, testVersionMatrix(), true))
+	check.Equal(t, ""+
+		`{"attr_name":"go","versions":{"24.05":"1.22.3","unstable":"1.22.5"}}`+"\n"+
+		`{"attr_name":"go_1_23","versions":{"24.05":null,"unstable":"1.23.0"}}`+"\n",
+		out.String(),
+	)
+}
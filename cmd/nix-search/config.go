@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+)
+
+// Config holds the settings that can be read from the config file. Each one
+// can be overridden by an environment variable, and some of them by flags:
+//
+//	config key     environment variable      flag
+//	endpoint       NIX_SEARCH_ENDPOINT       --endpoint
+//	index_prefix   NIX_SEARCH_INDEX_PREFIX   --index-prefix
+//	username       NIX_SEARCH_USERNAME
+//	password       NIX_SEARCH_PASSWORD
+//
+// Empty values fall back to the search.nixos.org defaults, except for the index
+// prefix, which can be explicitly set to "" for clusters whose index names are
+// not prefixed at all, and the credentials, which are only sent to another
+// endpoint if they're set.
+type Config struct {
+	Endpoint    string  `json:"endpoint"`
+	IndexPrefix *string `json:"index_prefix"`
+	Username    string  `json:"username"`
+	Password    string  `json:"password"`
+}
+
+// configPath returns the location of the config file, which is
+// $NIX_SEARCH_CONFIG if set, or nix-search/config.json inside of the user's
+// config directory ($XDG_CONFIG_HOME, ~/.config, ~/Library/Application
+// Support, ...)
+func configPath() (string, error) {
+	if path := os.Getenv("NIX_SEARCH_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "nix-search", "config.json"), nil
+}
+
+// loadConfig reads the config file, if there is one, and then applies any
+// overrides from the environment and from the flags of the running command.
+func loadConfig(c *cobra.Command) (Config, error) {
+	var config Config
+	path, err := configPath()
+	if err != nil {
+		return config, err
+	}
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return config, err
+	default:
+		if err := json.Unmarshal(b, &config); err != nil {
+			return config, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	config.Endpoint = firstOf(*rootFlags.Endpoint, os.Getenv("NIX_SEARCH_ENDPOINT"), config.Endpoint)
+	if prefix, ok := os.LookupEnv("NIX_SEARCH_INDEX_PREFIX"); ok {
+		config.IndexPrefix = &prefix
+	}
+	if c.Flags().Changed("index-prefix") {
+		config.IndexPrefix = rootFlags.IndexPrefix
+	}
+	config.Username = firstOf(os.Getenv("NIX_SEARCH_USERNAME"), config.Username)
+	config.Password = firstOf(os.Getenv("NIX_SEARCH_PASSWORD"), config.Password)
+	return config, nil
+}
+
+// newClient returns a client configured by the config file, environment, and
+// flags. With --offline, searches are answered from the channels downloaded
+// by "nix-search sync". Otherwise, unless --no-cache is passed, responses are
+// cached on disk and reused for --cache-ttl, and expired responses are used as
+// a fallback when the cluster can't be reached.
+func newClient(c *cobra.Command) (nixsearch.Client, error) {
+	if *rootFlags.Offline {
+		mirror, err := newMirror()
+		if err != nil {
+			return nil, err
+		}
+		return nixsearch.NewLocalClient(mirror.Dir)
+	}
+	esclient, err := newElasticSearchClient(c)
+	if err != nil {
+		return nil, err
+	}
+	if *rootFlags.NoCache {
+		return esclient, nil
+	}
+	if *rootFlags.CacheTTL <= 0 {
+		return nil, errors.New("--cache-ttl must be greater than 0, use --no-cache to skip the cache")
+	}
+	return nixsearch.NewCachingClient(esclient, nixsearch.CacheOptions{
+		TTL:          *rootFlags.CacheTTL,
+		Scope:        esclient.BaseURL + "/" + esclient.IndexPrefix,
+		StaleIfError: true,
+		OnStale: func(_ string, cachedAt time.Time, err error) {
+			warning := color.New(color.FgYellow, color.Italic).Sprintf(
+				"warning: showing stale results cached %s ago: %s",
+				time.Since(cachedAt).Round(time.Second), err,
+			)
+			fmt.Fprintln(os.Stderr, warning)
+		},
+	})
+}
+
+func newElasticSearchClient(c *cobra.Command) (*nixsearch.ElasticSearchClient, error) {
+	config, err := loadConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return nixsearch.NewElasticSearchClientWithOptions(nixsearch.ClientOptions{
+		BaseURL:     config.Endpoint,
+		IndexPrefix: config.IndexPrefix,
+		Username:    config.Username,
+		Password:    config.Password,
+	})
+}
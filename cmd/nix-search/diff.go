@@ -0,0 +1,167 @@
+//nolint:gochecknoglobals
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+	"github.com/peterldowns/nix-search-cli/pkg/render"
+)
+
+var diffCommand = &cobra.Command{
+	Use:   "diff --from channel [--to channel] [flags]",
+	Short: "list the packages that were added, removed, or changed between channels",
+	Example: CLIExample(`
+# Compare every package in two releases. This fetches every package
+# in both channels, so it's much faster with "nix-search sync" and
+# --offline
+nix-search diff --from 23.11 --to 24.05
+# ... only some packages, by name or package set
+nix-search diff --from 23.11 --to 24.05 --name 'nodejs'
+nix-search diff --from 23.11 --to 24.05 --attr-set python3Packages
+# ... as json, one line per changed package
+nix-search diff --from stable --to unstable --attr-set gnome --json
+	`),
+	Args: cobra.NoArgs,
+	RunE: diff,
+}
+
+var diffFlags struct {
+	From    *string
+	To      *string
+	Name    *string
+	AttrSet *string
+}
+
+func diff(c *cobra.Command, _ []string) error {
+	if err := checkJSONLines(c.Name()); err != nil {
+		return err
+	}
+	if *diffFlags.From == "" {
+		return errors.New("--from is required")
+	}
+	if *rootFlags.Flakes {
+		return errors.New("diff only works with channels, not --flakes")
+	}
+	var query nixsearch.Query
+	if x := *diffFlags.Name; x != "" {
+		query.Name = &nixsearch.MatchName{Name: x}
+	}
+	if x := *diffFlags.AttrSet; x != "" {
+		set := nixsearch.Query{Name: &nixsearch.MatchName{Name: strings.TrimSuffix(x, ".") + "."}}
+		query.AllOf = append(query.AllOf, set)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client, err := newClient(c)
+	if err != nil {
+		return err
+	}
+	from, err := checkChannel(ctx, client, *diffFlags.From)
+	if err != nil {
+		return err
+	}
+	to, err := checkChannel(ctx, client, *diffFlags.To)
+	if err != nil {
+		return err
+	}
+	result, err := nixsearch.DiffChannels(ctx, client, query, from, to)
+	if err != nil {
+		return err
+	}
+	return printDiff(os.Stdout, result, jsonOutput())
+}
+
+// printDiff writes the changes between the channels to w, grouped by kind
+// and followed by a summary, or a line of JSON for each change if asJSON is
+// set.
+func printDiff(w io.Writer, diff nixsearch.ChannelDiff, asJSON bool) error {
+	var out strings.Builder
+	if asJSON {
+		for _, change := range diff.Changes {
+			bytes, _ := json.Marshal(change)
+			out.Write(bytes)
+			out.WriteString("\n")
+		}
+		_, err := io.WriteString(w, out.String())
+		return err
+	}
+
+	// removed (1)
+	//   python2 2.7.18
+	// upgraded (2, 1 major)
+	//   go 1.21.9 -> 1.22.3
+	//   nodejs 18.19.1 -> 20.12.2 (major)
+	width := 0
+	for _, change := range diff.Changes {
+		width = max(width, len(change.AttrName))
+	}
+	for _, kind := range []nixsearch.ChangeKind{nixsearch.Removed, nixsearch.Added, nixsearch.Upgraded, nixsearch.Downgraded} {
+		count := diff.Count(kind)
+		if count == 0 {
+			continue
+		}
+		heading := fmt.Sprintf("%s (%s", kind, render.Count(count))
+		if majors := countMajorBumps(diff, kind); majors != 0 {
+			heading += fmt.Sprintf(", %s major", render.Count(majors))
+		}
+		fmt.Fprintln(&out, color.New(color.Bold).Sprint(heading+")"))
+		for _, change := range diff.Changes {
+			if change.Kind != kind {
+				continue
+			}
+			fmt.Fprintf(&out, "  %-*s  %s\n", width, change.AttrName, formatChange(change))
+		}
+	}
+
+	// 23.11 -> 24.05: 1 added, 1 removed, 2 upgraded, 1 downgraded, 1 unchanged
+	fmt.Fprintln(&out, color.New(color.Faint).Sprintf(
+		"%s -> %s: %s added, %s removed, %s upgraded, %s downgraded, %s unchanged",
+		diff.From,
+		diff.To,
+		render.Count(diff.Count(nixsearch.Added)),
+		render.Count(diff.Count(nixsearch.Removed)),
+		render.Count(diff.Count(nixsearch.Upgraded)),
+		render.Count(diff.Count(nixsearch.Downgraded)),
+		render.Count(diff.Unchanged),
+	))
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func countMajorBumps(diff nixsearch.ChannelDiff, kind nixsearch.ChangeKind) int {
+	var n int
+	for _, change := range diff.Changes {
+		if change.Kind == kind && change.MajorBump {
+			n++
+		}
+	}
+	return n
+}
+
+// formatChange formats the versions of a changed package, like
+// "18.19.1 -> 20.12.2 (major)".
+func formatChange(change nixsearch.PackageChange) string {
+	switch change.Kind {
+	case nixsearch.Added:
+		return formatVersion(change.To)
+	case nixsearch.Removed:
+		return color.New(color.FgRed, color.Faint).Sprint(change.From)
+	}
+	out := formatVersion(change.From) + " -> " + formatVersion(change.To)
+	if change.MajorBump {
+		out += " " + color.New(color.FgYellow).Sprint("(major)")
+	}
+	return out
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+)
+
+func testChannelDiff() nixsearch.ChannelDiff {
+	return nixsearch.ChannelDiff{
+		From: "23.11",
+		To:   "24.05",
+		Changes: []nixsearch.PackageChange{
+			{AttrName: "go", Kind: nixsearch.Upgraded, From: "1.21.9", To: "1.22.3"},
+			{AttrName: "nodejs", Kind: nixsearch.Upgraded, From: "18.19.1", To: "20.12.2", MajorBump: true},
+			{AttrName: "python2", Kind: nixsearch.Removed, From: "2.7.18"},
+			{AttrName: "uv", Kind: nixsearch.Added, To: "0.1.45"},
+		},
+		Unchanged: 1234,
+	}
+}
+
+func TestPrintDiff(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	assert.NoError(t, printDiff(&out, testChannelDiff(), false))
+	check.Equal(t, ""+
+		"removed (1)\n"+
+		"  python2  2.7.18\n"+
+		"added (1)\n"+
+		"  uv       0.1.45\n"+
+		"upgraded (2, 1 major)\n"+
+		"  go       1.21.9 -> 1.22.3\n"+
+		"  nodejs   18.19.1 -> 20.12.2 (major)\n"+
+		"23.11 -> 24.05: 1 added, 1 removed, 2 upgraded, 0 downgraded, 1,234 unchanged\n",
+		out.String(),
+	)
+
+	// Without any changes, only the summary is printed.
+	out.Reset()
+	assert.NoError(t, printDiff(&out, nixsearch.ChannelDiff{From: "24.05", To: "24.05", Unchanged: 2}, false))
+	check.Equal(t, "24.05 -> 24.05: 0 added, 0 removed, 0 upgraded, 0 downgraded, 2 unchanged\n", out.String())
+}
+
+func TestPrintDiffJSON(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	assert.NoError(t, printDiff(&out, testChannelDiff(), true))
+	check.Equal(t, ""+
+		`{"attr_name":"go","change":"upgraded","from":"1.21.9","to":"1.22.3"}`+"\n"+
+		`{"attr_name":"nodejs","change":"upgraded","from":"18.19.1","to":"20.12.2","major_bump":true}`+"\n"+
+		`{"attr_name":"python2","change":"removed","from":"2.7.18"}`+"\n"+
+		`{"attr_name":"uv","change":"added","to":"0.1.45"}`+"\n",
+		out.String(),
+	)
+}
@@ -1,158 +1,112 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"slices"
-	"sort"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
-	escapes "github.com/snugfox/ansi-escapes"
 
 	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+	"github.com/peterldowns/nix-search-cli/pkg/render"
 )
 
-func printResults(query nixsearch.Query, packages []nixsearch.Package) {
-	shouldReverseOrder := (rootFlags.Reverse != nil && *rootFlags.Reverse)
-	if shouldReverseOrder {
-		slices.Reverse(packages)
+// renderOptions returns the options for rendering results to stdout: with
+// colours unless they've been turned off, and with hyperlinks, a footer,
+// tables that fit on one line, and packages that aren't available on this
+// machine marked as such when stdout is a terminal.
+func renderOptions(flakes bool, channel string) render.Options {
+	opts := render.Options{
+		Channel:    resultChannel(flakes, channel),
+		Color:      !color.NoColor,
+		Hyperlinks: isTerminal,
+		Footer:     isTerminal,
+		Fields:     *rootFlags.Fields,
 	}
-	for _, pkg := range packages {
-		printResult(query, pkg)
+	if width, ok := terminalWidth(); ok && isTerminal {
+		opts.Width = width
 	}
-}
-
-func printResult(query nixsearch.Query, pkg nixsearch.Package) {
-	// { ... pkg contents ... }
-	shouldOutputJSON := (rootFlags.JSON != nil && *rootFlags.JSON)
-	if shouldOutputJSON {
-		bytes, _ := json.Marshal(pkg)
-		fmt.Println(string(bytes))
-		return
+	if platform, ok := nixsearch.CurrentPlatform(); ok && isTerminal {
+		opts.Platform = platform
 	}
-
-	// name @ version: program1 program2 ...
-	shouldOutputDetails := (rootFlags.Details != nil && *rootFlags.Details)
-	if !shouldOutputDetails {
-		name := formatName(query, pkg)
-		fmt.Print(name)
-		if pkg.Version != "" {
-			version := formatVersion("@ " + pkg.Version)
-			fmt.Print(" ", version)
-		}
-		if len(pkg.Programs) > 0 {
-			programs := formatPrograms(query, pkg.Programs)
-			fmt.Print(" : ", programs)
-		}
-		fmt.Print("\n")
-		return
-	}
-
-	// examplePkg
-	//  version: 3.1
-	//  programs: hello goodbye true false
-	//  description: examplePkg is a made up package as an example.
-	//  homepage: [https://example.com]
-	//    - https://example.com/one
-	//    - https://example.org/two
-	//  license: [Single License Result Shown On One Line]
-	//    - Multiple License
-	//    - Results Shown Over Multiple Lines
-	name := formatName(query, pkg)
-	fmt.Print(name, "\n")
-	version := formatVersion(pkg.Version)
-	fmt.Printf("  version: %s\n", version)
-	programs := formatPrograms(query, pkg.Programs)
-	fmt.Printf("  programs: %s\n", programs)
-	description := formatDescription(pkg)
-	fmt.Printf("  description: %s\n", description)
-	fmt.Print("  homepage:")
-	fmt.Print(formatList(imap(func(s string) string {
-		return formatLink(s, s, color.Underline)
-	}, pkg.Homepage)))
-	fmt.Print("  license:")
-	fmt.Print(formatList(imap(formatLicense, pkg.Licenses)))
+	return opts
 }
 
-func formatName(query nixsearch.Query, pkg nixsearch.Package) string {
-	if pkg.IsFlake() {
-		url := fmt.Sprintf(
-			`https://search.nixos.org/flakes?show=%s&query=%s`,
-			pkg.AttrName,
-			pkg.AttrName,
-		)
-		return formatLink(url, pkg.ID(), color.Underline, color.FgWhite)
+// resultChannel returns the channel to report results as coming from, or ""
+// for flakes, which don't belong to a channel.
+func resultChannel(flakes bool, channel string) string {
+	if flakes {
+		return ""
 	}
-	url := fmt.Sprintf(`https://search.nixos.org/packages?channel=%s&show=%s`, query.Channel, pkg.AttrName)
-	return formatLink(url, pkg.ID())
+	return channel
 }
 
-func formatVersion(version string) string {
-	return color.New(color.FgGreen, color.Faint).Sprint(version)
+func printResults(query nixsearch.Query, result nixsearch.SearchResult, format string, took time.Duration) error {
+	opts := renderOptions(query.Flakes, query.Channel)
+	opts.Query = query
+	opts.AllPages = *rootFlags.All
+	opts.Took = took
+	renderer, err := render.New(format, opts)
+	if err != nil {
+		return err
+	}
+	shouldReverseOrder := (rootFlags.Reverse != nil && *rootFlags.Reverse)
+	if shouldReverseOrder {
+		slices.Reverse(result.Hits)
+	}
+	return renderer.Render(os.Stdout, result)
 }
 
-func formatPrograms(query nixsearch.Query, programs []string) string {
-	if len(programs) == 0 {
-		return ""
+// printInstallCommands prints the command that installs each result with
+// the method, instead of the results themselves.
+func printInstallCommands(query nixsearch.Query, result nixsearch.SearchResult, method nixsearch.InstallMethod) error {
+	shouldReverseOrder := (rootFlags.Reverse != nil && *rootFlags.Reverse)
+	if shouldReverseOrder {
+		slices.Reverse(result.Hits)
 	}
-	if isTerminal {
-		var matches []string
-		var others []string
-		// Dim all the programs that aren't what you searched for
-		for _, program := range programs {
-			if query.ExactlyMatches(program) {
-				matches = append(matches, color.New(color.Bold).Sprint(program))
-			} else {
-				others = append(others, color.New(color.Faint).Sprint(program))
-			}
+	channel := resultChannel(query.Flakes, query.Channel)
+	for _, hit := range result.Hits {
+		command, err := hit.InstallCommand(method, channel)
+		if err != nil {
+			return err
 		}
-		sort.Strings(matches)
-		sort.Strings(others)
-		matches = append(matches, others...)
-		programs = matches
+		fmt.Println(command)
 	}
-	return strings.Join(programs, " ")
-}
-
-func formatDescription(pkg nixsearch.Package) string {
-	return firstOf(pkg.FlakeDescription, pkg.Description)
+	return nil
 }
 
-func formatLicense(license nixsearch.License) string {
-	return formatLink(license.URL, license.FullName, color.Underline)
+func printOptions(query nixsearch.OptionQuery, options []nixsearch.Option) error {
+	format := "compact"
+	switch {
+	case jsonOutput():
+		format = "json"
+	case rootFlags.Details != nil && *rootFlags.Details:
+		format = "details"
+	}
+	renderer, err := render.NewOptionRenderer(format, renderOptions(query.Flakes, query.Channel))
+	if err != nil {
+		return err
+	}
+	shouldReverseOrder := (rootFlags.Reverse != nil && *rootFlags.Reverse)
+	if shouldReverseOrder {
+		slices.Reverse(options)
+	}
+	return renderer.RenderOptions(os.Stdout, options)
 }
 
-func formatLink(url, text string, attrs ...color.Attribute) string {
-	if url != "" {
-		var c *color.Color
-		if attrs != nil {
-			// Optional styling takes precedence
-			c = color.New(attrs...)
-		} else {
-			// Default styling
-			c = color.New(color.Underline, color.FgBlue)
-		}
-		if isTerminal {
-			return escapes.Link(url, c.Sprint(text))
-		}
-		return c.Sprint(text)
-	}
-	return text
+func formatVersion(version string) string {
+	return color.New(color.FgGreen, color.Faint).Sprint(version)
 }
 
-func formatList(data []string) string {
-	if len(data) == 0 {
-		return "\n"
-	}
-	if len(data) == 1 {
-		return fmt.Sprintf(" %s\n", data[0])
-	}
-	fmt.Printf("\n")
-	out := strings.Builder{}
-	out.WriteString("\n")
-	for _, s := range data {
-		out.WriteString(fmt.Sprintf("    - %s\n", s))
+// formatParseError adds the query, with a marker pointing to the problem,
+// to a [nixsearch.ParseError].
+func formatParseError(err error) error {
+	var parseErr nixsearch.ParseError
+	if !errors.As(err, &parseErr) {
+		return err
 	}
-	return out.String()
+	return fmt.Errorf("%w\n\n    %s\n    %s^", err, parseErr.Query, strings.Repeat(" ", parseErr.Pos))
 }
@@ -0,0 +1,567 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+	"github.com/peterldowns/nix-search-cli/pkg/render"
+)
+
+// searchDelay is how long the picker waits for typing to stop before it
+// searches.
+const searchDelay = 150 * time.Millisecond
+
+// errCancelled is returned when the picker is closed without choosing a
+// package.
+var errCancelled = errors.New("cancelled") //nolint:gochecknoglobals
+
+// interactive runs a full-screen picker that searches as you type, starting
+// with the text, and prints the attr of the package that's chosen, or the
+// command to install it with the method chosen with --install-cmd.
+func interactive(c *cobra.Command, text string) error {
+	if jsonOutput() || *rootFlags.Format != "" || *rootFlags.All {
+		return errors.New("--interactive can not be combined with --json, --format, or --all")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	installMethod := nixsearch.InstallProfile
+	if x := *rootFlags.InstallCmd; x != "" {
+		method, err := nixsearch.ParseInstallMethod(x)
+		if err != nil {
+			return fmt.Errorf("--install-cmd: %w", err)
+		}
+		installMethod = method
+	}
+	dedupe, err := nixsearch.ParseDedupeStrategy(*rootFlags.Dedupe)
+	if err != nil {
+		return fmt.Errorf("--dedupe: %w", err)
+	}
+	// Flags like --program and --license narrow down every search.
+	filters, err := rootFilters()
+	if err != nil {
+		return err
+	}
+	client, err := newClient(c)
+	if err != nil {
+		return err
+	}
+	p := &picker{
+		client:        client,
+		text:          text,
+		channel:       *rootFlags.Channel,
+		flakes:        *rootFlags.Flakes,
+		maxResults:    *rootFlags.MaxResults,
+		filters:       filters,
+		dedupe:        dedupe,
+		installMethod: installMethod,
+	}
+	p.channel, err = checkChannel(ctx, client, p.channel)
+	if err != nil {
+		return err
+	}
+	p.channels = []string{p.channel}
+	if lister, ok := client.(nixsearch.ChannelLister); ok {
+		if channels, err := lister.ListChannels(ctx); err == nil && len(channels) != 0 {
+			p.channels = p.channels[:0]
+			for _, channel := range channels {
+				p.channels = append(p.channels, channel.Name)
+			}
+		}
+	}
+	if platform, ok := nixsearch.CurrentPlatform(); ok {
+		p.platform = platform
+	}
+
+	tty, restore, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	// Draw on the alternate screen, so that the terminal looks the same as
+	// it did before once the picker is closed.
+	fmt.Fprint(tty, "\x1b[?1049h")
+	chosen, err := p.run(ctx, tty)
+	fmt.Fprint(tty, "\x1b[?1049l")
+	restore()
+	if err != nil {
+		return err
+	}
+	fmt.Println(chosen)
+	return nil
+}
+
+// picker is the state of the interactive picker.
+type picker struct {
+	client        nixsearch.Client
+	channels      []string // the channels that tab cycles through
+	channel       string
+	flakes        bool
+	maxResults    int
+	filters       nixsearch.Query // from flags like --program, see rootFilters
+	dedupe        nixsearch.DedupeStrategy
+	platform      string
+	installMethod nixsearch.InstallMethod // for ctrl-x
+
+	text     string
+	results  []nixsearch.Package
+	selected int
+	offset   int // the index of the first result that fits on the screen
+	// status is shown below the query: "searching…", an error, or what
+	// happened after the last key press.
+	status    string
+	statusErr bool
+
+	width, height int
+}
+
+// searchResult is the result of the search for the query with the
+// sequence number seq. Only the result of the latest search is shown.
+type searchResult struct {
+	seq     int
+	results []nixsearch.Package
+	err     error
+}
+
+// run handles key presses and search results until a package is chosen, and
+// returns what should be printed for it.
+func (p *picker) run(ctx context.Context, tty *os.File) (string, error) {
+	out := bufio.NewWriter(tty)
+	var err error
+	p.width, p.height, err = terminalSize(tty)
+	if err != nil {
+		return "", err
+	}
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+
+	keys := make(chan []key)
+	go readKeys(tty, keys)
+
+	results := make(chan searchResult)
+	seq := 0
+	cancelSearch := func() {}
+	defer func() { cancelSearch() }()
+	// Search straight away for any text given on the command line.
+	search := time.After(0)
+
+	for {
+		if err := p.draw(out); err != nil {
+			return "", err
+		}
+		select {
+		case pressed, ok := <-keys:
+			if !ok {
+				return "", errCancelled
+			}
+			for _, k := range pressed {
+				textBefore, channelBefore, flakesBefore := p.text, p.channel, p.flakes
+				chosen, done := p.handle(k)
+				if done {
+					if chosen == "" {
+						return "", errCancelled
+					}
+					return chosen, nil
+				}
+				if p.text != textBefore || p.channel != channelBefore || p.flakes != flakesBefore {
+					// Stop waiting for the last search, and search again once
+					// typing stops.
+					cancelSearch()
+					search = time.After(searchDelay)
+				}
+			}
+		case <-search:
+			seq++
+			query, ok, err := p.query()
+			switch {
+			case err != nil:
+				p.setStatus(err.Error(), true)
+			case !ok:
+				p.results = nil
+				p.setStatus("", false)
+			default:
+				searchCtx, cancel := context.WithCancel(ctx)
+				cancelSearch = cancel
+				go p.search(searchCtx, seq, query, results)
+				p.setStatus("searching…", false)
+			}
+		case result := <-results:
+			if result.seq != seq {
+				continue
+			}
+			switch {
+			case errors.Is(result.err, context.Canceled):
+			case result.err != nil:
+				p.setStatus(result.err.Error(), true)
+			default:
+				p.results = result.results
+				p.selected, p.offset = 0, 0
+				p.setStatus("", false)
+			}
+		case <-resized:
+			if width, height, err := terminalSize(tty); err == nil {
+				p.width, p.height = width, height
+			}
+		}
+	}
+}
+
+// search searches for the query, and sends the results, or the error, to
+// results unless the search has been cancelled.
+func (p *picker) search(ctx context.Context, seq int, query nixsearch.Query, results chan<- searchResult) {
+	result := searchResult{seq: seq}
+	found, err := nixsearch.SearchVersionRange(ctx, p.client, query)
+	if err != nil {
+		result.err = err
+	} else {
+		found.Hits = nixsearch.DeduplicateHitsBy(found.Hits, p.dedupe)
+		result.results = found.Packages()
+	}
+	select {
+	case results <- result:
+	case <-ctx.Done():
+	}
+}
+
+// query returns the query for the text that's been typed, which can use the
+// query language, like "program:rg -license:unfree", narrowed down by the
+// filters. It returns false if there's nothing to search for.
+func (p *picker) query() (nixsearch.Query, bool, error) {
+	query := p.filters
+	query.Channel = p.channel
+	query.Flakes = p.flakes
+	query.MaxResults = p.maxResults
+	text := strings.TrimSpace(p.text)
+	switch {
+	case text == "":
+	case nixsearch.HasQueryFields(text):
+		parsed, err := nixsearch.ParseQuery(text)
+		if err != nil {
+			return query, false, err
+		}
+		addParsedQuery(&query, parsed)
+	default:
+		query.Search = &nixsearch.MatchSearch{Search: text}
+	}
+	return query, !query.IsEmpty(), nil
+}
+
+func (p *picker) setStatus(status string, isErr bool) {
+	p.status, p.statusErr = status, isErr
+}
+
+// handle updates the picker after a key press. It returns true once the
+// picker should close, along with what should be printed, which is "" if
+// the picker was cancelled.
+func (p *picker) handle(k key) (string, bool) {
+	switch k {
+	case keyEsc, ctrl('c'), ctrl('d'), ctrl('g'):
+		return "", true
+	case keyEnter:
+		if pkg, ok := p.current(); ok {
+			return pkg.ID(), true
+		}
+	case ctrl('x'):
+		if pkg, ok := p.current(); ok {
+			command, err := pkg.InstallCommand(p.installMethod, resultChannel(p.flakes, p.channel))
+			if err != nil {
+				p.setStatus(err.Error(), true)
+				break
+			}
+			return command, true
+		}
+	case keyUp, ctrl('p'):
+		p.move(-1)
+	case keyDown, ctrl('n'):
+		p.move(1)
+	case keyPageUp:
+		p.move(-p.listHeight())
+	case keyPageDown:
+		p.move(p.listHeight())
+	case keyBackspace:
+		if p.text != "" {
+			_, size := utf8.DecodeLastRuneInString(p.text)
+			p.text = p.text[:len(p.text)-size]
+		}
+	case ctrl('u'):
+		p.text = ""
+	case ctrl('w'):
+		p.text = strings.TrimRight(p.text, " ")
+		p.text = p.text[:strings.LastIndex(p.text, " ")+1]
+	case keyTab:
+		if !p.flakes {
+			i := slices.Index(p.channels, p.channel)
+			p.channel = p.channels[(i+1)%len(p.channels)]
+		}
+	case ctrl('f'):
+		p.flakes = !p.flakes
+	case ctrl('o'):
+		p.openHomepage()
+	default:
+		if k >= ' ' {
+			p.text += string(rune(k))
+		}
+	}
+	return "", false
+}
+
+// current returns the selected package, if there are any results.
+func (p *picker) current() (nixsearch.Package, bool) {
+	if p.selected >= len(p.results) {
+		return nixsearch.Package{}, false
+	}
+	return p.results[p.selected], true
+}
+
+// move moves the selection by delta results, scrolling the list to keep it
+// on the screen.
+func (p *picker) move(delta int) {
+	if len(p.results) == 0 {
+		return
+	}
+	p.selected = min(max(p.selected+delta, 0), len(p.results)-1)
+	height := p.listHeight()
+	if p.selected < p.offset {
+		p.offset = p.selected
+	} else if p.selected >= p.offset+height {
+		p.offset = p.selected - height + 1
+	}
+}
+
+// openHomepage opens the selected package's homepage in a browser.
+func (p *picker) openHomepage() {
+	pkg, ok := p.current()
+	if !ok {
+		return
+	}
+	if len(pkg.Homepage) == 0 {
+		p.setStatus(pkg.ID()+" doesn't have a homepage", true)
+		return
+	}
+	opener := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		opener = "open"
+	}
+	cmd := exec.Command(opener, pkg.Homepage[0])
+	if err := cmd.Start(); err != nil {
+		p.setStatus(fmt.Sprintf("could not open %s: %s", pkg.Homepage[0], err), true)
+		return
+	}
+	go func() { _ = cmd.Wait() }()
+	p.setStatus("opened "+pkg.Homepage[0], false)
+}
+
+// pickerHelp lists the keys that the picker responds to, along the bottom of
+// the screen.
+const pickerHelp = "enter: print attr · ctrl-x: print install command · tab: channel · ctrl-f: flakes · ctrl-o: homepage · esc: quit"
+
+// listHeight is how many results are shown at once: about half of the rows
+// that aren't used for the query, the status, and the help.
+func (p *picker) listHeight() int {
+	return max(1, (p.height-4)/2)
+}
+
+// draw redraws the whole screen:
+//
+//	> query
+//	  unstable · 20 results
+//	> result 1
+//	  result 2
+//	────────────
+//	details of the selected result
+//	keys
+func (p *picker) draw(out *bufio.Writer) error {
+	var lines []string
+	lines = append(lines, "> "+p.text)
+
+	source := p.channel
+	if p.flakes {
+		source = "flakes"
+	}
+	status := fmt.Sprintf("%s · %s results", source, render.Count(len(p.results)))
+	switch {
+	case p.statusErr:
+		status = tuiStyle(source+" · "+p.status, color.FgRed)
+	case p.status != "":
+		status = tuiStyle(source+" · "+p.status, color.Faint)
+	default:
+		status = tuiStyle(status, color.Faint)
+	}
+	lines = append(lines, "  "+status)
+
+	height := p.listHeight()
+	for i := p.offset; i < p.offset+height; i++ {
+		if i >= len(p.results) {
+			lines = append(lines, "")
+			continue
+		}
+		pkg := p.results[i]
+		row := pkg.ID()
+		if pkg.Version != "" {
+			row += " @ " + pkg.Version
+		}
+		if i == p.selected {
+			lines = append(lines, tuiStyle(truncate("> "+row, p.width), color.Bold, color.ReverseVideo))
+		} else {
+			lines = append(lines, truncate("  "+row, p.width))
+		}
+	}
+	lines = append(lines, tuiStyle(strings.Repeat("─", p.width), color.Faint))
+
+	// The details pane is the --details output for the selected package,
+	// without colours, so that it can be cut to fit the screen.
+	if pkg, ok := p.current(); ok {
+		var details bytes.Buffer
+		renderer, err := render.New("details", render.Options{Channel: resultChannel(p.flakes, p.channel), Platform: p.platform})
+		if err != nil {
+			return err
+		}
+		result := nixsearch.SearchResult{Hits: []nixsearch.PackageHit{{Package: pkg}}}
+		if err := renderer.Render(&details, result); err != nil {
+			return err
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(details.String(), "\n"), "\n") {
+			lines = append(lines, truncate(line, p.width))
+		}
+	}
+
+	// Fill the screen, with the help on the last row.
+	if len(lines) > p.height-1 {
+		lines = lines[:max(0, p.height-1)]
+	}
+	for len(lines) < p.height-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, tuiStyle(truncate(pickerHelp, p.width), color.Faint))
+
+	fmt.Fprint(out, "\x1b[?25l\x1b[H")
+	for i, line := range lines {
+		if i != 0 {
+			fmt.Fprint(out, "\r\n")
+		}
+		fmt.Fprint(out, line, "\x1b[K")
+	}
+	// Put the cursor at the end of the query.
+	fmt.Fprintf(out, "\x1b[1;%dH\x1b[?25h", min(p.width, 3+utf8.RuneCountInString(p.text)))
+	return out.Flush()
+}
+
+// tuiStyle styles text for the picker, which always draws on a terminal
+// even if stdout isn't one, so colours are only turned off by $NO_COLOR.
+func tuiStyle(text string, attrs ...color.Attribute) string {
+	if os.Getenv("NO_COLOR") != "" {
+		return text
+	}
+	c := color.New(attrs...)
+	c.EnableColor()
+	return c.Sprint(text)
+}
+
+// truncate cuts text down to width characters.
+func truncate(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	return string([]rune(text)[:max(0, width)])
+}
+
+// key is a key press: either a character, including control characters like
+// ctrl('c'), or one of the special keys below.
+type key rune
+
+// Keys that don't have a character of their own.
+const (
+	keyUp key = -(iota + 1)
+	keyDown
+	keyLeft
+	keyRight
+	keyPageUp
+	keyPageDown
+	keyEsc
+	keyEnter     key = '\r'
+	keyTab       key = '\t'
+	keyBackspace key = 0x7f
+)
+
+// ctrl returns the key for a letter pressed with ctrl.
+func ctrl(letter byte) key {
+	return key(letter & 0x1f)
+}
+
+// readKeys reads key presses from the terminal and sends them to keys, until
+// the terminal is closed.
+func readKeys(tty *os.File, keys chan<- []key) {
+	defer close(keys)
+	buf := make([]byte, 256)
+	for {
+		n, err := tty.Read(buf)
+		if err != nil {
+			return
+		}
+		keys <- decodeKeys(buf[:n])
+	}
+}
+
+// escapeSequences are the sequences that terminals send for special keys,
+// after the escape character.
+var escapeSequences = map[string]key{ //nolint:gochecknoglobals
+	"[A": keyUp, "OA": keyUp,
+	"[B": keyDown, "OB": keyDown,
+	"[C": keyRight, "OC": keyRight,
+	"[D": keyLeft, "OD": keyLeft,
+	"[5~": keyPageUp,
+	"[6~": keyPageDown,
+}
+
+// decodeKeys decodes the keys in a single read from the terminal. Escape
+// followed by "[" or "O" starts a sequence for a special key, and unknown
+// sequences are ignored; escape followed by anything else is the escape key.
+func decodeKeys(b []byte) []key {
+	var keys []key
+	for len(b) != 0 {
+		switch {
+		case b[0] == 0x1b && len(b) > 1 && (b[1] == '[' || b[1] == 'O'):
+			// "O" is followed by a single letter, like "OA", and "[" by
+			// anything up to a letter or "~", like "[5~".
+			end := 2
+			if b[1] == '[' {
+				end = bytes.IndexFunc(b[2:], func(r rune) bool {
+					return r == '~' || ('A' <= r && r <= 'Z') || ('a' <= r && r <= 'z')
+				}) + 2
+			}
+			if end < 2 || end >= len(b) {
+				return keys
+			}
+			if k, ok := escapeSequences[string(b[1:end+1])]; ok {
+				keys = append(keys, k)
+			}
+			b = b[end+1:]
+		case b[0] == 0x1b:
+			keys = append(keys, keyEsc)
+			b = b[1:]
+		case b[0] == '\n':
+			keys = append(keys, keyEnter)
+			b = b[1:]
+		case b[0] == 0x08:
+			keys = append(keys, keyBackspace)
+			b = b[1:]
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, key(r))
+			b = b[size:]
+		}
+	}
+	return keys
+}
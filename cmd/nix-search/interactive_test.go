@@ -0,0 +1,223 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+	"github.com/peterldowns/nix-search-cli/pkg/nixversion"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  []key
+	}{
+		{"text", "rg", []key{'r', 'g'}},
+		{"unicode", "é", []key{'é'}},
+		{"enter", "\r\n", []key{keyEnter, keyEnter}},
+		{"backspace", "\x7f\x08", []key{keyBackspace, keyBackspace}},
+		{"ctrl", "\x03\x18", []key{ctrl('c'), ctrl('x')}},
+		{"escape", "\x1b", []key{keyEsc}},
+		{"arrows", "\x1b[A\x1b[B\x1bOC\x1bOD", []key{keyUp, keyDown, keyRight, keyLeft}},
+		{"pages", "\x1b[5~\x1b[6~", []key{keyPageUp, keyPageDown}},
+		{"arrow then text", "\x1b[Bab", []key{keyDown, 'a', 'b'}},
+		// Escape followed by a key that doesn't start a sequence is the
+		// escape key, and the key after it isn't lost.
+		{"escape then text", "\x1bab", []key{keyEsc, 'a', 'b'}},
+		{"escape twice", "\x1b\x1b", []key{keyEsc, keyEsc}},
+		// Unknown and unfinished sequences are ignored.
+		{"unknown sequence", "\x1b[1;5Cx", []key{'x'}},
+		{"unfinished sequence", "a\x1b[1;", []key{'a'}},
+		{"unfinished ss3", "a\x1bO", []key{'a'}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			check.Equal(t, tc.want, decodeKeys([]byte(tc.input)))
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+
+	check.Equal(t, "ripgrep", truncate("ripgrep", 7))
+	check.Equal(t, "ripgrep", truncate("ripgrep", 80))
+	check.Equal(t, "rip", truncate("ripgrep", 3))
+	check.Equal(t, "", truncate("ripgrep", 0))
+	check.Equal(t, "", truncate("ripgrep", -1))
+	check.Equal(t, "──", truncate("────", 2))
+}
+
+func testPicker() *picker {
+	return &picker{
+		channels:   []string{"unstable", "24.05", "23.11"},
+		channel:    "unstable",
+		maxResults: 20,
+		height:     10, // 3 results fit on the screen
+		results: []nixsearch.Package{
+			{AttrName: "ripgrep"},
+			{AttrName: "ripgrep-all"},
+			{AttrName: "ugrep"},
+			{AttrName: "gnugrep"},
+			{AttrName: "sift"},
+		},
+	}
+}
+
+func TestPickerMove(t *testing.T) {
+	t.Parallel()
+	p := testPicker()
+	check.Equal(t, 3, p.listHeight())
+
+	p.move(1)
+	check.Equal(t, 1, p.selected)
+	check.Equal(t, 0, p.offset)
+
+	// The list scrolls to keep the selection on the screen.
+	p.move(2)
+	check.Equal(t, 3, p.selected)
+	check.Equal(t, 1, p.offset)
+
+	// The selection stops at either end.
+	p.move(10)
+	check.Equal(t, 4, p.selected)
+	check.Equal(t, 2, p.offset)
+	p.move(-10)
+	check.Equal(t, 0, p.selected)
+	check.Equal(t, 0, p.offset)
+
+	// Without results, there's nothing to select.
+	p.results = nil
+	p.move(1)
+	check.Equal(t, 0, p.selected)
+}
+
+func TestPickerHandle(t *testing.T) {
+	t.Parallel()
+
+	p := testPicker()
+	for _, k := range []key{'r', 'g', 'x', keyBackspace, ' ', 'a', 'l', 'l'} {
+		_, done := p.handle(k)
+		check.False(t, done)
+	}
+	check.Equal(t, "rg all", p.text)
+	p.handle(ctrl('w'))
+	check.Equal(t, "rg ", p.text)
+	p.handle(ctrl('u'))
+	check.Equal(t, "", p.text)
+	p.handle(keyBackspace)
+	check.Equal(t, "", p.text)
+
+	p.handle(keyDown)
+	p.handle(ctrl('n'))
+	p.handle(keyUp)
+	check.Equal(t, 1, p.selected)
+	p.handle(keyPageDown)
+	check.Equal(t, 4, p.selected)
+	p.handle(keyPageUp)
+	check.Equal(t, 1, p.selected)
+
+	// Tab cycles through the channels, but not while searching flakes.
+	p.handle(keyTab)
+	check.Equal(t, "24.05", p.channel)
+	p.handle(keyTab)
+	p.handle(keyTab)
+	check.Equal(t, "unstable", p.channel)
+	p.handle(ctrl('f'))
+	check.True(t, p.flakes)
+	p.handle(keyTab)
+	check.Equal(t, "unstable", p.channel)
+	p.handle(ctrl('f'))
+
+	chosen, done := p.handle(keyEnter)
+	check.True(t, done)
+	check.Equal(t, "ripgrep-all", chosen)
+
+	p.installMethod = nixsearch.InstallShell
+	chosen, done = p.handle(ctrl('x'))
+	check.True(t, done)
+	check.Equal(t, "nix shell nixpkgs#ripgrep-all", chosen)
+
+	for _, k := range []key{keyEsc, ctrl('c'), ctrl('d'), ctrl('g')} {
+		chosen, done := p.handle(k)
+		check.True(t, done)
+		check.Equal(t, "", chosen)
+	}
+
+	// Enter does nothing until there's a result to choose.
+	p.results = nil
+	_, done = p.handle(keyEnter)
+	check.False(t, done)
+}
+
+func TestPickerQuery(t *testing.T) {
+	t.Parallel()
+
+	p := testPicker()
+	_, ok, err := p.query()
+	assert.NoError(t, err)
+	check.False(t, ok)
+
+	p.text = "  ripgrep "
+	query, ok, err := p.query()
+	assert.NoError(t, err)
+	check.True(t, ok)
+	check.Equal(t, nixsearch.Query{
+		Channel:    "unstable",
+		MaxResults: 20,
+		Search:     &nixsearch.MatchSearch{Search: "ripgrep"},
+	}, query)
+
+	p.text = "program:rg"
+	query, ok, err = p.query()
+	assert.NoError(t, err)
+	check.True(t, ok)
+	check.Equal(t, []nixsearch.Query{{Program: &nixsearch.MatchProgram{Program: "rg"}}}, query.AllOf)
+
+	p.text = "program:"
+	_, ok, err = p.query()
+	check.Error(t, err)
+	check.False(t, ok)
+}
+
+func TestPickerQueryFilters(t *testing.T) {
+	t.Parallel()
+
+	atLeast14, err := nixversion.ParseConstraint(">=14")
+	assert.NoError(t, err)
+	below15, err := nixversion.ParseConstraint("<15")
+	assert.NoError(t, err)
+	p := testPicker()
+	p.filters = nixsearch.Query{
+		AllOf:         []nixsearch.Query{{Program: &nixsearch.MatchProgram{Program: "rg"}}},
+		License:       &nixsearch.MatchLicense{License: "MIT"},
+		ExcludeUnfree: true,
+		VersionRange:  &atLeast14,
+	}
+
+	// The filters are enough to search without any text.
+	query, ok, err := p.query()
+	assert.NoError(t, err)
+	check.True(t, ok)
+	check.Equal(t, p.filters.License, query.License)
+	check.True(t, query.ExcludeUnfree)
+
+	// Version ranges that are typed apply along with the filters'.
+	p.text = "version:<15 name:ripgrep"
+	query, _, err = p.query()
+	assert.NoError(t, err)
+	assert.NotNil(t, query.VersionRange)
+	check.Equal(t, atLeast14.And(below15).String(), query.VersionRange.String())
+	check.Equal(t, 2, len(query.AllOf))
+	check.Nil(t, query.AllOf[1].VersionRange)
+
+	// Searches never change the filters.
+	check.Equal(t, 1, len(p.filters.AllOf))
+	check.Equal(t, ">=14", p.filters.VersionRange.String())
+}
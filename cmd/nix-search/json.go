@@ -0,0 +1,108 @@
+//nolint:gochecknoglobals
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+)
+
+// The modes that --json accepts.
+const (
+	jsonLines    = "lines"    // one result per line
+	jsonArray    = "array"    // a single array of results
+	jsonEnvelope = "envelope" // a single document with the results and the query
+)
+
+// jsonFormats are the render formats that each --json mode prints package
+// search results with. Other commands print one result per line in every
+// mode.
+var jsonFormats = map[string]string{
+	jsonLines:    "json",
+	jsonArray:    "json-array",
+	jsonEnvelope: "json-envelope",
+}
+
+// jsonMode returns the mode chosen with --json, or "" if there is none.
+// --json=true is the same as --json, and --json=false the same as leaving it
+// out, like any other boolean flag.
+func jsonMode() (string, error) {
+	if rootFlags.JSON == nil {
+		return "", nil
+	}
+	switch mode := *rootFlags.JSON; mode {
+	case "", "false":
+		return "", nil
+	case "true":
+		return jsonLines, nil
+	case jsonLines, jsonArray, jsonEnvelope:
+		return mode, nil
+	default:
+		return "", usageError{fmt.Errorf("--json: unknown mode %q, expected one of: %s, %s, %s", mode, jsonLines, jsonArray, jsonEnvelope)}
+	}
+}
+
+// checkJSONLines returns an error if --json chose a mode other than one
+// result per line, which is the only mode that commands other than the
+// package search support.
+func checkJSONLines(command string) error {
+	mode, err := jsonMode()
+	if err != nil {
+		return err
+	}
+	if mode != "" && mode != jsonLines {
+		return usageError{fmt.Errorf("--json=%s only works when searching for packages, %s prints one result per line with --json", mode, command)}
+	}
+	return nil
+}
+
+// jsonOutput reports whether the output is JSON, chosen with --json or
+// --format, in which case errors are printed as JSON too.
+func jsonOutput() bool {
+	if mode, err := jsonMode(); err != nil || mode != "" {
+		return true
+	}
+	return rootFlags.Format != nil && strings.HasPrefix(*rootFlags.Format, "json")
+}
+
+// jsonError is how errors are printed when the output is JSON, on stdout
+// rather than stderr, so that scripts can read them in place of results.
+// Every field is always present.
+type jsonError struct {
+	Error jsonErrorDetails `json:"error"`
+}
+
+type jsonErrorDetails struct {
+	// Kind is what went wrong: "channel_not_found", "not_synced",
+	// "unauthorized", "rate_limited", "upstream_unavailable",
+	// "bad_query_string", "bad_query", or "error" for anything else.
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	// Hint suggests how to fix the error, or is "".
+	Hint string `json:"hint"`
+	// ExitCode is the code that nix-search exits with.
+	ExitCode int `json:"exit_code"`
+	// Status is the HTTP status of the response from ElasticSearch, or 0
+	// if there wasn't one.
+	Status int `json:"status"`
+}
+
+// printJSONError prints the error as a jsonError.
+func printJSONError(err error, explained explanation) {
+	details := jsonErrorDetails{
+		Kind:     explained.kind,
+		Message:  err.Error(),
+		Hint:     explained.hint,
+		ExitCode: explained.code,
+	}
+	var apiErr nixsearch.APIError
+	if errors.As(err, &apiErr) {
+		details.Status = apiErr.StatusCode
+	}
+	bytes, _ := json.Marshal(jsonError{Error: details})
+	fmt.Fprintln(os.Stdout, string(bytes))
+}
@@ -3,14 +3,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"runtime"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
 	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+	"github.com/peterldowns/nix-search-cli/pkg/nixversion"
+	"github.com/peterldowns/nix-search-cli/pkg/render"
 )
 
 var rootCommand = &cobra.Command{
@@ -28,19 +34,98 @@ nix-search --name 'emacsPackages.*'
 # ... by version
 nix-search --version 1.20 
 nix-search --version '1.*'           
+# ... by a range of versions, compared the same way as nix does
+nix-search --name 'python3*' --version-range '>=3.11 <3.13'
+nix-search --program go --version-range '~1.22'
 # ... by installed programs
 nix-search --program python
 nix-search --program "py*"
 # ... with ElasticSearch QueryString syntax
 nix-search --query-string="package_programs:(crystal OR irb)"
 nix-search --query-string='package_description:(MIT Scheme)'
-# ... on a specific channel, default "unstable". The valid channel
-#     values are what the search.nixos.org index has, check
-#     that website to see what options they show in their interface.
+#     with short aliases for the common fields: program, attr, pname,
+#     desc, version, license, maintainer, and platform
+nix-search --query-string='program:(crystal OR irb) AND -desc:ruby'
+# ... on a specific channel, default "unstable". To see the valid
+#     channel values, run "nix-search channels".
 nix-search --channel=unstable python3
+# ... or on the newest release ("stable"), or the one before it
+#     ("oldstable" or "previous-stable")
+nix-search --channel=stable python3
 # ... or flakes indexed by search.nixos.org, see their website
 #     for more information.
 nix-search --flakes wayland
+# ... or NixOS options, see "nix-search options --help"
+nix-search options nginx
+# ... page through results, or fetch every match
+nix-search --name 'python3Packages.*' --page 2
+nix-search --name 'python3Packages.*' --all
+# ... matching any of several values, or excluding some
+nix-search --name=ripgrep --name=fd
+nix-search --program=python --exclude-name=python2
+nix-search --license=MIT --license=Apache-2.0 --exclude-license=GPL-3.0-only
+# ... by license, with an SPDX id or the license's full name
+nix-search --license=GPL-3.0-or-later --name='gnome-*'
+nix-search --license='MIT License' ripgrep
+# ... by maintainer, with a GitHub handle or their name
+nix-search --maintainer=peterldowns
+nix-search --maintainer='Peter Downs' --details
+# ... without any unfree packages
+nix-search --exclude-unfree terraform
+# ... only packages that can be installed on a given system, or
+#     on this machine
+nix-search --platform=aarch64-darwin --program=strace
+nix-search --platform=current --program=strace
+
+# ... against a mirror of the search.nixos.org cluster. These can
+#     also be set with $NIX_SEARCH_ENDPOINT/$NIX_SEARCH_INDEX_PREFIX
+#     or in ~/.config/nix-search/config.json
+nix-search --endpoint=https://search.example.com --index-prefix='mirror-' python3
+
+# ... results are cached for an hour, and stale results are used
+#     if search.nixos.org can't be reached
+nix-search --cache-ttl=10m python3
+nix-search --no-cache python3
+# ... or without network access, after downloading the channel
+#     with "nix-search sync"
+nix-search --offline python3
+
+# ... or with the query language, combining any of the fields
+#     program, name, version, license, maintainer, and platform.
+#     Terms starting with "-" exclude results; quote the query so
+#     that they aren't mistaken for flags.
+nix-search 'program:rg name:ripgrep -license:unfree platform:current'
+nix-search 'maintainer:"Peter Downs" -name:python2'
+nix-search 'program:go version:>=1.22'
+
+# ... showing the newest version of packages that are in more
+#     than one generation of the index, rather than the most
+#     relevant one
+nix-search --dedupe=newest-version python3
+
+# ... in other formats, for pasting into spreadsheets and PRs
+nix-search --format=table python3
+nix-search --format=csv --fields=attr,version,license --name 'python3Packages.*'
+nix-search --format=markdown --fields=attr,version,programs ripgrep
+nix-search --format=yaml --fields=attr,version,platforms go
+nix-search --format='template={{.AttrName}}@{{.Version}}: {{join .Programs ","}}' go
+
+# ... as JSON, one result per line, as a single array, or as a
+#     single document with the query and the total number of results.
+#     Errors are printed to stdout as {"error": {...}}.
+nix-search --json python3
+nix-search --json=array python3
+nix-search --json=envelope python3
+
+# ... printing the command that installs each result, from the
+#     channel that was searched
+nix-search --install-cmd=profile ripgrep
+nix-search --install-cmd=shell --channel=24.05 ripgrep
+nix-search --install-cmd=flake-ref --flakes nix-search
+
+# ... or interactively, searching as you type and printing the
+#     attr of the package you pick, or the command to install it
+nix-search -i python3
 
 # ... or search with multiple filters and options
 nix-search golang --program go --version '1.*' --details
@@ -51,67 +136,292 @@ nix-search golang --program go --version '1.*' --details
 }
 
 var rootFlags struct {
-	Channel     *string
-	Flakes      *bool
-	Search      *string
-	Program     *string
-	Name        *string
-	Version     *string
-	QueryString *string
-	JSON        *bool
+	Channel      *string
+	Flakes       *bool
+	Search       *string
+	Program      *[]string
+	Name         *[]string
+	Version      *[]string
+	VersionRange *string
+	QueryString  *string
+	Platform     *string
+	License      *[]string
+	NoUnfree     *bool
+	Maintainer   *[]string
+
+	ExcludeProgram    *[]string
+	ExcludeName       *[]string
+	ExcludeVersion    *[]string
+	ExcludeLicense    *[]string
+	ExcludeMaintainer *[]string
+
+	Interactive *bool
+	InstallCmd  *string
+	JSON        *string
 	Details     *bool
+	Format      *string
+	Fields      *[]string
 	MaxResults  *int
+	Page        *int
+	All         *bool
 	Reverse     *bool
+	Dedupe      *string
+	Endpoint    *string
+	IndexPrefix *string
+	CacheTTL    *time.Duration
+	NoCache     *bool
+	Offline     *bool
+	DataDir     *string
 }
 
 func root(c *cobra.Command, args []string) error {
+	if *rootFlags.Interactive {
+		return interactive(c, strings.Join(args, " "))
+	}
 	channel := *rootFlags.Channel
 	search := *rootFlags.Search
 	if len(args) != 0 && search == "" {
 		search = strings.Join(args, " ")
 	}
 
-	query := nixsearch.Query{
-		Channel:    channel,
-		Flakes:     *rootFlags.Flakes,
-		MaxResults: *rootFlags.MaxResults,
+	if *rootFlags.Page < 1 {
+		return fmt.Errorf("--page must be at least 1, got %d", *rootFlags.Page)
 	}
-	if x := search; x != "" {
-		query.Search = &nixsearch.MatchSearch{Search: x}
+	format, err := rootOutputFormat()
+	if err != nil {
+		return err
 	}
-	if x := *rootFlags.Program; x != "" {
-		query.Program = &nixsearch.MatchProgram{Program: x}
+	installMethod, err := rootInstallMethod()
+	if err != nil {
+		return err
 	}
-	if x := *rootFlags.Name; x != "" {
-		query.Name = &nixsearch.MatchName{Name: x}
+	dedupe, err := nixsearch.ParseDedupeStrategy(*rootFlags.Dedupe)
+	if err != nil {
+		return fmt.Errorf("--dedupe: %w", err)
 	}
-	if x := *rootFlags.Version; x != "" {
-		query.Version = &nixsearch.MatchVersion{Version: x}
+	query, err := rootFilters()
+	if err != nil {
+		return err
 	}
-	if x := *rootFlags.QueryString; x != "" {
-		query.QueryString = &nixsearch.MatchQueryString{QueryString: x}
+	query.Channel = channel
+	query.Flakes = *rootFlags.Flakes
+	query.MaxResults = *rootFlags.MaxResults
+	query.From = (*rootFlags.Page - 1) * *rootFlags.MaxResults
+	// Positional arguments that use the query language, like "program:rg
+	// -license:unfree", are parsed instead of searched for as text.
+	if len(args) != 0 && *rootFlags.Search == "" && nixsearch.HasQueryFields(search) {
+		parsed, err := nixsearch.ParseQuery(search)
+		if err != nil {
+			return formatParseError(err)
+		}
+		addParsedQuery(&query, parsed)
+		search = ""
+	}
+	if x := search; x != "" {
+		query.Search = &nixsearch.MatchSearch{Search: x}
 	}
 
 	// If the user doesn't give any search terms or any flags, show the
 	// program's usage information and exit.
 	if query.IsEmpty() {
+		if query.VersionRange != nil {
+			return usageError{errors.New("a version range only filters the results of a search, add a search term or a flag like --program or --name")}
+		}
 		return c.Help()
 	}
 
 	ctx := context.Background()
-	client, err := nixsearch.NewElasticSearchClient()
+	client, err := newClient(c)
 	if err != nil {
 		return err
 	}
+	if !query.Flakes {
+		query.Channel, err = checkChannel(ctx, client, query.Channel)
+		if err != nil {
+			return err
+		}
+	}
 
-	packages, err := client.Search(ctx, query)
+	var result nixsearch.SearchResult
+	start := time.Now()
+	if *rootFlags.All {
+		for pkg, searchErr := range client.SearchAll(ctx, query) {
+			if searchErr != nil {
+				err = searchErr
+				break
+			}
+			if query.VersionRange != nil && !query.VersionRange.Matches(pkg.Version) {
+				continue
+			}
+			result.Hits = append(result.Hits, nixsearch.PackageHit{Package: pkg})
+		}
+	} else {
+		// The index can't compare versions, so any version range is
+		// applied here, fetching more results as needed.
+		result, err = nixsearch.SearchVersionRange(ctx, client, query)
+	}
+	took := time.Since(start)
 	if err != nil {
 		return err
 	}
-	packages = nixsearch.Deduplicate(packages)
+	found := len(result.Hits)
+	result.Hits = nixsearch.DeduplicateHitsBy(result.Hits, dedupe)
+	// The total counted the duplicates that were just removed.
+	result.Total -= found - len(result.Hits)
+	if *rootFlags.All {
+		result.Total = len(result.Hits)
+	}
+
+	if installMethod != "" {
+		return printInstallCommands(query, result, installMethod)
+	}
+	return printResults(query, result, format, took)
+}
+
+// rootInstallMethod returns the method chosen with --install-cmd, or "" if
+// the results should be printed as usual.
+func rootInstallMethod() (nixsearch.InstallMethod, error) {
+	name := *rootFlags.InstallCmd
+	if name == "" {
+		return "", nil
+	}
+	if *rootFlags.Format != "" || jsonOutput() || *rootFlags.Details {
+		return "", errors.New("--install-cmd can not be combined with --format, --json, or --details")
+	}
+	method, err := nixsearch.ParseInstallMethod(name)
+	if err != nil {
+		return "", fmt.Errorf("--install-cmd: %w", err)
+	}
+	if method == nixsearch.InstallNixEnv && *rootFlags.Flakes {
+		return "", errors.New("--install-cmd=nix-env can not be combined with --flakes, because nix-env can not install flakes")
+	}
+	return method, nil
+}
+
+// rootOutputFormat returns the format chosen with --format, --json, or
+// --details, after checking that it and the fields chosen with --fields
+// exist.
+func rootOutputFormat() (string, error) {
+	mode, err := jsonMode()
+	if err != nil {
+		return "", err
+	}
+	name := *rootFlags.Format
+	switch {
+	case name != "" && (mode != "" || *rootFlags.Details):
+		return "", errors.New("--format can not be combined with --json or --details")
+	case mode != "":
+		name = jsonFormats[mode]
+	case *rootFlags.Details:
+		name = "details"
+	case name == "":
+		name = "compact"
+	}
+	if len(*rootFlags.Fields) != 0 && !render.HasFields(name) {
+		return "", usageError{errors.New("--fields only works with --format=table, csv, tsv, markdown, or yaml")}
+	}
+	if _, err := render.New("compact", render.Options{Fields: *rootFlags.Fields}); err != nil {
+		return "", fmt.Errorf("--fields: %w", err)
+	}
+	if _, err := render.New(name, render.Options{}); err != nil {
+		return "", fmt.Errorf("--format: %w", err)
+	}
+	return name, nil
+}
+
+// rootFilters returns a query with the matchers chosen with flags like
+// --program and --exclude-license, and the version range chosen with
+// --version-range, but without any search text, channel, or pagination.
+func rootFilters() (nixsearch.Query, error) {
+	var query nixsearch.Query
+	if x := *rootFlags.VersionRange; x != "" {
+		constraint, err := nixversion.ParseConstraint(x)
+		if err != nil {
+			return query, fmt.Errorf("--version-range: %w", err)
+		}
+		query.VersionRange = &constraint
+	}
+	if x := *rootFlags.QueryString; x != "" {
+		if _, err := nixsearch.RewriteQueryString(x); err != nil {
+			return query, formatParseError(err)
+		}
+		query.QueryString = &nixsearch.MatchQueryString{QueryString: x}
+	}
+	// Each of these flags can be repeated: the results match any of the
+	// values, and none of the excluded values.
+	for _, flag := range []struct {
+		values, excluded []string
+		set              func(q *nixsearch.Query, x string)
+	}{
+		{*rootFlags.Program, *rootFlags.ExcludeProgram, func(q *nixsearch.Query, x string) {
+			q.Program = &nixsearch.MatchProgram{Program: x}
+		}},
+		{*rootFlags.Name, *rootFlags.ExcludeName, func(q *nixsearch.Query, x string) {
+			q.Name = &nixsearch.MatchName{Name: x}
+		}},
+		{*rootFlags.Version, *rootFlags.ExcludeVersion, func(q *nixsearch.Query, x string) {
+			q.Version = &nixsearch.MatchVersion{Version: x}
+		}},
+		{*rootFlags.License, *rootFlags.ExcludeLicense, func(q *nixsearch.Query, x string) {
+			q.License = &nixsearch.MatchLicense{License: x}
+		}},
+		{*rootFlags.Maintainer, *rootFlags.ExcludeMaintainer, func(q *nixsearch.Query, x string) {
+			q.Maintainer = &nixsearch.MatchMaintainer{Maintainer: x}
+		}},
+	} {
+		addMatches(&query, flag.values, flag.set)
+		for _, x := range flag.excluded {
+			var excluded nixsearch.Query
+			flag.set(&excluded, x)
+			query.Not = append(query.Not, excluded)
+		}
+	}
+	query.ExcludeUnfree = *rootFlags.NoUnfree
+	if x := *rootFlags.Platform; x != "" {
+		if x == "current" {
+			platform, ok := nixsearch.CurrentPlatform()
+			if !ok {
+				return query, fmt.Errorf("--platform=current: nix doesn't support %s/%s", runtime.GOOS, runtime.GOARCH)
+			}
+			x = platform
+		}
+		query.Platform = &nixsearch.MatchPlatform{Platform: x}
+	}
+	return query, nil
+}
+
+// addParsedQuery adds a query parsed with [nixsearch.ParseQuery] to the
+// query. Its version range applies to the whole query, and has to be
+// satisfied along with any range that's already set, like --version-range.
+func addParsedQuery(query *nixsearch.Query, parsed nixsearch.Query) {
+	if parsed.VersionRange != nil {
+		if query.VersionRange != nil {
+			both := query.VersionRange.And(*parsed.VersionRange)
+			parsed.VersionRange = &both
+		}
+		query.VersionRange, parsed.VersionRange = parsed.VersionRange, nil
+	}
+	// Clip, so that queries built from the same filters never share the
+	// same AllOf.
+	query.AllOf = append(slices.Clip(query.AllOf), parsed)
+}
 
-	printResults(query, packages)
-	return nil
+// addMatches adds a matcher to the query for each of the values, so that the
+// query matches any one of them. A single value is set directly on the query.
+func addMatches(query *nixsearch.Query, values []string, set func(q *nixsearch.Query, x string)) {
+	switch len(values) {
+	case 0:
+	case 1:
+		set(query, values[0])
+	default:
+		var group nixsearch.Query
+		for _, x := range values {
+			var sub nixsearch.Query
+			set(&sub, x)
+			group.AnyOf = append(group.AnyOf, sub)
+		}
+		query.AllOf = append(query.AllOf, group)
+	}
 }
 
 func main() {
@@ -119,18 +429,63 @@ func main() {
 	rootCommand.SilenceErrors = true
 	rootCommand.SilenceUsage = true
 	rootCommand.TraverseChildren = true
+	rootCommand.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return usageError{err}
+	})
 
 	rootFlags.Search = rootCommand.Flags().StringP("search", "s", "", "default search, same as the website")
-	rootFlags.Channel = rootCommand.Flags().StringP("channel", "c", "unstable", "which channel to search in")
-	rootFlags.Program = rootCommand.Flags().StringP("program", "p", "", "search by installed programs")
-	rootFlags.Name = rootCommand.Flags().StringP("name", "n", "", "search by package name")
+	rootFlags.Channel = rootCommand.PersistentFlags().StringP("channel", "c", "unstable", "which channel to search in, like 'unstable', '24.05', or 'stable'")
+	rootFlags.Program = rootCommand.Flags().StringArrayP("program", "p", nil, "search by installed programs (repeatable, matches any)")
+	rootFlags.Name = rootCommand.Flags().StringArrayP("name", "n", nil, "search by package name (repeatable, matches any)")
 	rootFlags.QueryString = rootCommand.Flags().StringP("query-string", "q", "", "search by elasticsearch querystring")
-	rootFlags.JSON = rootCommand.Flags().BoolP("json", "j", false, "emit results in json-line format")
-	rootFlags.Details = rootCommand.Flags().BoolP("details", "d", false, "show expanded details for each result")
-	rootFlags.MaxResults = rootCommand.Flags().IntP("max-results", "m", 20, "maximum number of results to return")
-	rootFlags.Reverse = rootCommand.Flags().BoolP("reverse", "r", false, "print results in reverse order")
-	rootFlags.Version = rootCommand.Flags().StringP("version", "v", "", "search by version")
-	rootFlags.Flakes = rootCommand.Flags().BoolP("flakes", "f", false, "search flakes instead of nixpkgs")
+	rootFlags.Platform = rootCommand.Flags().String("platform", "", "only show packages available on a system, like 'aarch64-darwin', or 'current'")
+	rootFlags.License = rootCommand.Flags().StringArrayP("license", "l", nil, "search by license, either an SPDX id like 'MIT' or a full name (repeatable, matches any)")
+	rootFlags.Maintainer = rootCommand.Flags().StringArray("maintainer", nil, "search by maintainer, either a GitHub handle or a name (repeatable, matches any)")
+	rootFlags.NoUnfree = rootCommand.Flags().Bool("exclude-unfree", false, "don't show packages with unfree licenses (best effort, see the README)")
+	rootFlags.Interactive = rootCommand.Flags().BoolP("interactive", "i", false, "pick a package in a full-screen search that updates as you type, and print its attr")
+	rootFlags.InstallCmd = rootCommand.Flags().String("install-cmd", "", "print the command that installs each result: profile, nix-env, shell, run, or flake-ref")
+	rootFlags.JSON = rootCommand.PersistentFlags().StringP("json", "j", "", "emit results as json: one result per line, or with --json=array a single array, or with --json=envelope a single document that includes the query and total; errors are json too")
+	rootCommand.PersistentFlags().Lookup("json").NoOptDefVal = jsonLines
+	rootFlags.Details = rootCommand.PersistentFlags().BoolP("details", "d", false, "show expanded details for each result")
+	rootFlags.Format = rootCommand.Flags().String("format", "", "how to print results: "+strings.Join(render.Formats(), ", ")+" (default compact)")
+	rootFlags.Fields = rootCommand.Flags().StringSlice("fields", nil, "which columns to print with --format, like 'attr,version,programs,license' (default "+strings.Join(render.DefaultFields, ",")+")")
+	rootFlags.MaxResults = rootCommand.PersistentFlags().IntP("max-results", "m", 20, "maximum number of results to return")
+	rootFlags.Page = rootCommand.Flags().Int("page", 1, "which page of --max-results results to return")
+	rootFlags.All = rootCommand.Flags().BoolP("all", "a", false, "return every result, fetching --max-results per request")
+	rootFlags.Reverse = rootCommand.PersistentFlags().BoolP("reverse", "r", false, "print results in reverse order")
+	rootFlags.Dedupe = rootCommand.Flags().String("dedupe", string(nixsearch.DedupeFirst), "which entry to show for packages found in more than one index: first, newest-version, newest-index, or merge")
+	rootFlags.Version = rootCommand.Flags().StringArrayP("version", "v", nil, "search by version (repeatable, matches any)")
+	rootFlags.VersionRange = rootCommand.Flags().String("version-range", "", "only show versions in a range, like '>=3.11 <3.13', '~1.20', or '^2'")
+	rootFlags.ExcludeProgram = rootCommand.Flags().StringArray("exclude-program", nil, "exclude packages by installed programs (repeatable)")
+	rootFlags.ExcludeName = rootCommand.Flags().StringArray("exclude-name", nil, "exclude packages by package name (repeatable)")
+	rootFlags.ExcludeVersion = rootCommand.Flags().StringArray("exclude-version", nil, "exclude packages by version (repeatable)")
+	rootFlags.ExcludeLicense = rootCommand.Flags().StringArray("exclude-license", nil, "exclude packages by license (repeatable)")
+	rootFlags.ExcludeMaintainer = rootCommand.Flags().StringArray("exclude-maintainer", nil, "exclude packages by maintainer (repeatable)")
+	rootFlags.Flakes = rootCommand.PersistentFlags().BoolP("flakes", "f", false, "search flakes instead of nixpkgs")
+	rootFlags.Endpoint = rootCommand.PersistentFlags().String("endpoint", "", "url of the elasticsearch cluster to query (default search.nixos.org's)")
+	rootFlags.IndexPrefix = rootCommand.PersistentFlags().String("index-prefix", "", "prefix of the elasticsearch index names (default \"latest-*-\")")
+	rootFlags.CacheTTL = rootCommand.PersistentFlags().Duration("cache-ttl", nixsearch.DefaultCacheTTL, "how long to reuse cached results")
+	rootFlags.NoCache = rootCommand.PersistentFlags().Bool("no-cache", false, "don't read or write cached results")
+	rootFlags.Offline = rootCommand.PersistentFlags().Bool("offline", false, "search channels downloaded by 'nix-search sync' instead of the network")
+	rootFlags.DataDir = rootCommand.PersistentFlags().String("data-dir", "", "where 'nix-search sync' stores channels (default $XDG_DATA_HOME/nix-search)")
+
+	rootCommand.AddCommand(channelsCommand)
+
+	rootCommand.AddCommand(optionsCommand)
+	optionsFlags.Name = optionsCommand.Flags().StringP("name", "n", "", "search by option name")
+
+	rootCommand.AddCommand(compareCommand)
+	compareFlags.Channels = compareCommand.Flags().StringSlice("channels", []string{"unstable", "stable", "oldstable"}, "which channels to compare, like 'unstable,24.05,23.11'")
+	compareFlags.Workers = compareCommand.Flags().Int("workers", nixsearch.DefaultCompareWorkers, "how many channels to search at once")
+
+	rootCommand.AddCommand(diffCommand)
+	diffFlags.From = diffCommand.Flags().String("from", "", "the channel to compare from, like '23.11'")
+	diffFlags.To = diffCommand.Flags().String("to", "unstable", "the channel to compare to, like '24.05'")
+	diffFlags.Name = diffCommand.Flags().StringP("name", "n", "", "only compare packages by package name")
+	diffFlags.AttrSet = diffCommand.Flags().String("attr-set", "", "only compare packages in a package set, like 'python3Packages'")
+
+	rootCommand.AddCommand(syncCommand)
+	syncFlags.List = syncCommand.Flags().BoolP("list", "l", false, "list the channels that have been downloaded")
 
 	defer func() {
 		switch r := recover().(type) {
@@ -145,8 +500,78 @@ func main() {
 	}
 }
 
+// Exit codes, so that scripts can tell failures apart.
+const (
+	exitError           = 1 // anything not covered below
+	exitUsage           = 2 // a query or flag that can't be used
+	exitChannelNotFound = 3
+	exitUnauthorized    = 4
+	exitRateLimited     = 5
+	exitUnavailable     = 6
+	exitCancelled       = 130 // the interactive picker was closed, like fzf
+)
+
+// usageError is a flag or argument that can't be used, like an unknown flag
+// or a value that doesn't parse.
+type usageError struct {
+	error
+}
+
+func (e usageError) Unwrap() error {
+	return e.error
+}
+
 func onError(err error) {
+	explained := explainError(err)
+	if explained.kind == "cancelled" {
+		os.Exit(explained.code)
+	}
+	if jsonOutput() {
+		printJSONError(err, explained)
+		os.Exit(explained.code)
+	}
+	var qsErr nixsearch.QueryStringError
+	if errors.As(err, &qsErr) && qsErr.Query != "" && qsErr.Pos >= 0 {
+		err = fmt.Errorf("%w\n\n    %s\n    %s^", err, qsErr.Query, strings.Repeat(" ", qsErr.Pos))
+	}
 	errstr := color.New(color.FgRed, color.Italic).Sprint("error: ", err.Error())
 	fmt.Fprintln(os.Stderr, "\n", errstr)
-	os.Exit(1)
+	if explained.hint != "" {
+		fmt.Fprintln(os.Stderr, color.New(color.Faint).Sprint("\n  hint: ", explained.hint))
+	}
+	os.Exit(explained.code)
+}
+
+// explanation says what kind of error something is, how to fix it, if
+// there's a suggestion, and the code to exit with.
+type explanation struct {
+	kind string
+	hint string
+	code int
+}
+
+// explainError returns the explanation of an error.
+func explainError(err error) explanation {
+	switch {
+	case errors.Is(err, errCancelled):
+		return explanation{"cancelled", "", exitCancelled}
+	case errors.Is(err, nixsearch.ErrNotSynced):
+		return explanation{"not_synced", "download the channel first with \"nix-search sync --channel=...\", or search without --offline", exitChannelNotFound}
+	case errors.Is(err, nixsearch.ErrChannelNotFound):
+		return explanation{"channel_not_found", "run \"nix-search channels\" to see which channels can be searched", exitChannelNotFound}
+	case errors.Is(err, nixsearch.ErrUnauthorized):
+		return explanation{"unauthorized", "check the username and password in $NIX_SEARCH_USERNAME and $NIX_SEARCH_PASSWORD, or in the config file", exitUnauthorized}
+	case errors.Is(err, nixsearch.ErrRateLimited):
+		return explanation{"rate_limited", "wait a minute before searching again; results are cached for --cache-ttl, so repeating a search doesn't count", exitRateLimited}
+	case errors.Is(err, nixsearch.ErrUpstreamUnavailable):
+		return explanation{"upstream_unavailable", "check your network connection and --endpoint, or search a downloaded channel with --offline", exitUnavailable}
+	case errors.Is(err, nixsearch.ErrBadQueryString):
+		return explanation{"bad_query_string", "see https://www.elastic.co/guide/en/elasticsearch/reference/7.10/query-dsl-query-string-query.html#query-string-syntax", exitUsage}
+	case errors.As(err, new(nixsearch.ParseError)):
+		return explanation{"bad_query", "", exitUsage}
+	case errors.As(err, new(usageError)):
+		return explanation{"usage", "run \"nix-search --help\" to see which flags can be used", exitUsage}
+	default:
+		return explanation{"error", "", exitError}
+	}
 }
@@ -0,0 +1,75 @@
+//nolint:gochecknoglobals
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+)
+
+var optionsCommand = &cobra.Command{
+	Use:   "options some option [flags]",
+	Short: "search for NixOS options instead of packages",
+	Example: CLIExample(`
+# Search for NixOS options in the https://search.nixos.org/options index
+
+# ... like the web interface
+nix-search options nginx virtual hosts
+# ... by option name
+nix-search options --name services.nginx.
+nix-search options --name 'services.*.enable'
+# ... with the type, default, example, and declaration of each option
+nix-search options --name services.nginx.enable --details
+	`),
+	Args: cobra.ArbitraryArgs,
+	RunE: options,
+}
+
+var optionsFlags struct {
+	Name *string
+}
+
+func options(c *cobra.Command, args []string) error {
+	if err := checkJSONLines(c.Name()); err != nil {
+		return err
+	}
+	query := nixsearch.OptionQuery{
+		Channel:    *rootFlags.Channel,
+		Flakes:     *rootFlags.Flakes,
+		MaxResults: *rootFlags.MaxResults,
+		Search:     strings.Join(args, " "),
+		Name:       *optionsFlags.Name,
+	}
+	if query.IsEmpty() {
+		return c.Help()
+	}
+
+	if *rootFlags.Offline {
+		return errors.New("options can not be searched offline")
+	}
+	ctx := context.Background()
+	client, err := newClient(c)
+	if err != nil {
+		return err
+	}
+	searcher, ok := client.(nixsearch.OptionSearcher)
+	if !ok {
+		return fmt.Errorf("%T can not search options", client)
+	}
+	if !query.Flakes {
+		query.Channel, err = checkChannel(ctx, client, query.Channel)
+		if err != nil {
+			return err
+		}
+	}
+	opts, err := searcher.SearchOptions(ctx, query)
+	if err != nil {
+		return err
+	}
+	return printOptions(query, opts)
+}
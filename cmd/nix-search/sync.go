@@ -0,0 +1,116 @@
+//nolint:gochecknoglobals
+package main
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+)
+
+var syncCommand = &cobra.Command{
+	Use:   "sync [flags]",
+	Short: "download every package in a channel to search it offline",
+	Example: CLIExample(`
+# Download every package in the unstable channel
+nix-search sync
+# ... or in a specific channel, or in the flakes index
+nix-search sync --channel=24.05
+nix-search sync --flakes
+# ... and then search it without network access
+nix-search --offline --channel=24.05 python3
+# List the channels that have been downloaded
+nix-search sync --list
+	`),
+	Args: cobra.NoArgs,
+	RunE: syncChannel,
+}
+
+var syncFlags struct {
+	List *bool
+}
+
+func syncChannel(c *cobra.Command, _ []string) error {
+	mirror, err := newMirror()
+	if err != nil {
+		return err
+	}
+	if *syncFlags.List {
+		infos, err := mirror.List()
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			fmt.Printf(
+				"%s %s %s\n",
+				info.Index,
+				formatVersion(fmt.Sprintf("%d packages", info.Packages)),
+				color.New(color.Faint).Sprintf("synced %s", info.SyncedAt.Local().Format(time.DateTime)),
+			)
+		}
+		return nil
+	}
+
+	client, err := newElasticSearchClient(c)
+	if err != nil {
+		return err
+	}
+	query := nixsearch.Query{
+		Channel:    *rootFlags.Channel,
+		Flakes:     *rootFlags.Flakes,
+		MaxResults: 1000,
+	}
+	start := time.Now()
+	info, err := mirror.Sync(context.Background(), client, query, withProgress)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(
+		"synced %d packages from %s to %s in %s\n",
+		info.Packages,
+		info.Index,
+		mirror.Dir,
+		time.Since(start).Round(time.Millisecond),
+	)
+	return nil
+}
+
+// withProgress prints a running count of the packages that have been
+// downloaded, if the user is watching.
+func withProgress(packages iter.Seq2[nixsearch.Package, error]) iter.Seq2[nixsearch.Package, error] {
+	if !isTerminal {
+		return packages
+	}
+	return func(yield func(nixsearch.Package, error) bool) {
+		count := 0
+		defer fmt.Fprint(os.Stderr, "\r\033[K")
+		for pkg, err := range packages {
+			count++
+			if count%1000 == 0 {
+				fmt.Fprintf(os.Stderr, "\r\033[Kdownloaded %d packages...", count)
+			}
+			if !yield(pkg, err) {
+				return
+			}
+		}
+	}
+}
+
+// newMirror returns the mirror in --data-dir, or in the default location.
+func newMirror() (nixsearch.Mirror, error) {
+	dir := *rootFlags.DataDir
+	if dir == "" {
+		var err error
+		dir, err = nixsearch.DefaultMirrorDir()
+		if err != nil {
+			return nixsearch.Mirror{}, err
+		}
+	}
+	return nixsearch.Mirror{Dir: dir}, nil
+}
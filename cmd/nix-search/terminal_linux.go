@@ -0,0 +1,9 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+// The ioctl requests that read and change the settings of a terminal.
+const (
+	ioctlGetTermios = unix.TCGETS
+	ioctlSetTermios = unix.TCSETS
+)
@@ -0,0 +1,21 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"os"
+	"runtime"
+)
+
+// openTerminal returns an error, because raw mode is only implemented for
+// linux and the BSDs, including macOS.
+func openTerminal() (*os.File, func(), error) {
+	return nil, nil, errors.New("--interactive is not supported on " + runtime.GOOS)
+}
+
+func terminalSize(*os.File) (cols, rows int, err error) {
+	return 0, 0, errors.New("--interactive is not supported on " + runtime.GOOS)
+}
+
+func notifyResize(chan<- os.Signal) {}
@@ -0,0 +1,59 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// openTerminal opens the terminal that nix-search is running in, even if
+// stdin and stdout have been redirected, and puts it in raw mode so that
+// every key press can be read as soon as it's made. The returned func puts
+// the terminal back the way it was, and closes it.
+func openTerminal() (*os.File, func(), error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fd := int(tty.Fd())
+	original, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		_ = tty.Close()
+		return nil, nil, err
+	}
+	raw := *original
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Oflag &^= unix.OPOST
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &raw); err != nil {
+		_ = tty.Close()
+		return nil, nil, err
+	}
+	restore := func() {
+		_ = unix.IoctlSetTermios(fd, ioctlSetTermios, original)
+		_ = tty.Close()
+	}
+	return tty, restore, nil
+}
+
+// terminalSize returns the number of columns and rows of the terminal.
+func terminalSize(tty *os.File) (cols, rows int, err error) {
+	size, err := unix.IoctlGetWinsize(int(tty.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(size.Col), int(size.Row), nil
+}
+
+// notifyResize sends to c whenever the terminal is resized.
+func notifyResize(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGWINCH)
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+// terminalWidth returns false, because the width of the terminal can only be
+// found on unix systems.
+func terminalWidth() (int, bool) {
+	return 0, false
+}
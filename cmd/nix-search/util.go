@@ -17,15 +17,6 @@ func firstOf(s ...string) string {
 	return ""
 }
 
-// imap applies the function f to every element in the slice xs
-func imap[X any, Y any](f func(x X) Y, xs []X) []Y {
-	ys := make([]Y, 0, len(xs))
-	for _, x := range xs {
-		ys = append(ys, f(x))
-	}
-	return ys
-}
-
 // isTerminal will be true if we are outputting to a user shell. The value is
 // set during init time to avoid unnecessary calls to Stat.
 // The implementation is thanks to
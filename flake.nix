@@ -39,7 +39,7 @@
             #
             # (Yes, that's really how you're expected to do this.)
             #vendorHash = pkgs.lib.fakeHash;
-            vendorHash = "sha256-VlJ2OuHOTqIJeGUm2NbBiz33i8QTxaZnnm0JkVGkw1U=";
+            vendorHash = "sha256-nl1XCnH3X0yA4FZ5YVU8PGij0sCqcVLuFaxMhVIN2Qo=";
             src =
               let
                 # Set this to `true` in order to show all of the source files
@@ -1,6 +1,6 @@
 module github.com/peterldowns/nix-search-cli
 
-go 1.21
+go 1.23
 
 require (
 	github.com/google/go-cmp v0.7.0 // indirect
@@ -9,7 +9,6 @@ require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.17 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/sys v0.5.0 // indirect
 )
 
 require (
@@ -18,4 +17,5 @@ require (
 	github.com/peterldowns/testy v0.0.7
 	github.com/snugfox/ansi-escapes v0.2.0
 	github.com/spf13/cobra v1.8.1
+	golang.org/x/sys v0.5.0
 )
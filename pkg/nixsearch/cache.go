@@ -0,0 +1,300 @@
+package nixsearch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultCacheTTL is how long a [CachingClient] considers a cached response
+// to be fresh if no TTL is configured.
+const DefaultCacheTTL = time.Hour
+
+// CacheOptions configures a [CachingClient].
+type CacheOptions struct {
+	// Dir is where cached responses are stored. Defaults to "nix-search"
+	// inside of [os.UserCacheDir], which is $XDG_CACHE_HOME/nix-search on
+	// Linux.
+	Dir string
+	// TTL is how long a cached response is returned without asking the
+	// wrapped client. Defaults to [DefaultCacheTTL] when zero, and must not
+	// be negative.
+	TTL time.Duration
+	// Scope is mixed into every cache key, so that clients pointed at
+	// different clusters can share a cache directory without seeing each
+	// other's results. Usually this is the cluster's URL.
+	Scope string
+	// StaleIfError allows expired responses to be returned when the wrapped
+	// client fails, for instance because there is no network connection.
+	StaleIfError bool
+	// OnStale, if set, is called every time an expired response is returned
+	// in place of a fresh one, with the index that was searched (or
+	// "_aliases" for the list of channels), the time that the response was
+	// cached, and the error from the wrapped client.
+	OnStale func(index string, cachedAt time.Time, err error)
+}
+
+// CachingClient decorates a [Client], storing the results of each search on
+// disk and reusing them for identical queries until they expire. Entries are
+// keyed on the index and the serialized [Query.Payload], so any difference in
+// the query is a cache miss.
+type CachingClient struct {
+	Client Client
+	CacheOptions
+}
+
+// cacheEntry is the format of a single cached response on disk.
+type cacheEntry struct {
+	CachedAt time.Time     `json:"cached_at"`
+	Index    string        `json:"index"`
+	Result   *SearchResult `json:"result,omitempty"`
+	Channels []Channel     `json:"channels,omitempty"`
+	// Options isn't omitted when empty, so that a search for options that
+	// found none is still cached.
+	Options []Option `json:"options"`
+}
+
+func NewCachingClient(client Client, options CacheOptions) (*CachingClient, error) {
+	if options.Dir == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		options.Dir = filepath.Join(dir, "nix-search")
+	}
+	switch {
+	case options.TTL < 0:
+		return nil, fmt.Errorf("invalid cache TTL %s, it must not be negative", options.TTL)
+	case options.TTL == 0:
+		options.TTL = DefaultCacheTTL
+	}
+	return &CachingClient{
+		Client:       client,
+		CacheOptions: options,
+	}, nil
+}
+
+func (c *CachingClient) Search(ctx context.Context, query Query) ([]Package, error) {
+	result, err := c.SearchWithMeta(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return result.Packages(), nil
+}
+
+// SearchWithMeta returns a cached result if there is a fresh one, and
+// otherwise asks the wrapped client. If that fails and StaleIfError is set,
+// an expired result is returned instead, marked as [SearchResult.Stale].
+func (c *CachingClient) SearchWithMeta(ctx context.Context, query Query) (SearchResult, error) {
+	var err error
+	if !query.Flakes {
+		query.Channel, err = c.resolveChannel(ctx, query.Channel)
+		if err != nil {
+			return SearchResult{}, err
+		}
+	}
+	key, err := c.queryKey(query)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	entry, cached := c.read(key)
+	cached = cached && entry.Result != nil
+	if cached && time.Since(entry.CachedAt) < c.TTL {
+		return *entry.Result, nil
+	}
+
+	result, err := c.Client.SearchWithMeta(ctx, query)
+	if err != nil {
+		if cached && c.StaleIfError && ctx.Err() == nil {
+			c.onStale(query.Index(), entry, err)
+			stale := *entry.Result
+			stale.Stale = true
+			return stale, nil
+		}
+		return SearchResult{}, err
+	}
+	// The cache is best-effort; failing to write to it shouldn't fail the
+	// search.
+	_ = c.write(key, cacheEntry{
+		CachedAt: time.Now(),
+		Index:    query.Index(),
+		Result:   &result,
+	})
+	return result, nil
+}
+
+// SearchOptions searches for options with the wrapped client, if it is an
+// [OptionSearcher], and caches them just like search results. Expired options
+// that are returned because of StaleIfError are marked as [Option.Stale].
+func (c *CachingClient) SearchOptions(ctx context.Context, query OptionQuery) ([]Option, error) {
+	searcher, ok := c.Client.(OptionSearcher)
+	if !ok {
+		return nil, fmt.Errorf("%T can not search options", c.Client)
+	}
+	var err error
+	if !query.Flakes {
+		query.Channel, err = c.resolveChannel(ctx, query.Channel)
+		if err != nil {
+			return nil, err
+		}
+	}
+	payload, err := query.Payload()
+	if err != nil {
+		return nil, err
+	}
+	key := c.key(query.Index(), payload)
+	entry, cached := c.read(key)
+	cached = cached && entry.Options != nil
+	if cached && time.Since(entry.CachedAt) < c.TTL {
+		return entry.Options, nil
+	}
+	options, err := searcher.SearchOptions(ctx, query)
+	if err != nil {
+		if cached && c.StaleIfError && ctx.Err() == nil {
+			c.onStale(query.Index(), entry, err)
+			for i := range entry.Options {
+				entry.Options[i].Stale = true
+			}
+			return entry.Options, nil
+		}
+		return nil, err
+	}
+	if options == nil {
+		// Cache the lack of results as well.
+		options = []Option{}
+	}
+	_ = c.write(key, cacheEntry{
+		CachedAt: time.Now(),
+		Index:    query.Index(),
+		Options:  options,
+	})
+	return options, nil
+}
+
+// resolveChannel resolves a symbolic channel name using the cached channel
+// list, so that a cached search never outlives the release it was made
+// against. Other channel names are returned as they are.
+func (c *CachingClient) resolveChannel(ctx context.Context, channel string) (string, error) {
+	if !IsSymbolicChannel(channel) {
+		return channel, nil
+	}
+	if _, ok := c.Client.(ChannelLister); !ok {
+		return channel, nil
+	}
+	channels, err := c.ListChannels(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ResolveChannel(channels, channel)
+}
+
+// SearchAll is not cached, it delegates directly to the wrapped client.
+func (c *CachingClient) SearchAll(ctx context.Context, query Query) iter.Seq2[Package, error] {
+	return c.Client.SearchAll(ctx, query)
+}
+
+// ListChannels lists the channels known to the wrapped client, if it is a
+// [ChannelLister], and caches them just like search results. Expired channels
+// that are returned because of StaleIfError are marked as [Channel.Stale].
+func (c *CachingClient) ListChannels(ctx context.Context) ([]Channel, error) {
+	lister, ok := c.Client.(ChannelLister)
+	if !ok {
+		return nil, fmt.Errorf("%T can not list channels", c.Client)
+	}
+	key := c.key(aliasesIndex, nil)
+	entry, cached := c.read(key)
+	if cached && entry.Channels != nil && time.Since(entry.CachedAt) < c.TTL {
+		return entry.Channels, nil
+	}
+	channels, err := lister.ListChannels(ctx)
+	if err != nil {
+		if cached && entry.Channels != nil && c.StaleIfError && ctx.Err() == nil {
+			c.onStale(aliasesIndex, entry, err)
+			for i := range entry.Channels {
+				entry.Channels[i].Stale = true
+			}
+			return entry.Channels, nil
+		}
+		return nil, err
+	}
+	_ = c.write(key, cacheEntry{
+		CachedAt: time.Now(),
+		Channels: channels,
+	})
+	return channels, nil
+}
+
+// aliasesIndex is the cache key's index for the list of channels, after the
+// endpoint that it comes from.
+const aliasesIndex = "_aliases"
+
+func (c *CachingClient) onStale(index string, entry cacheEntry, err error) {
+	if c.OnStale != nil {
+		c.OnStale(index, entry.CachedAt, err)
+	}
+}
+
+func (c *CachingClient) queryKey(query Query) (string, error) {
+	payload, err := query.Payload()
+	if err != nil {
+		return "", err
+	}
+	return c.key(query.Index(), payload), nil
+}
+
+func (c *CachingClient) key(index string, payload []byte) string {
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(c.Scope), []byte(index), payload} {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *CachingClient) path(key string) string {
+	return filepath.Join(c.Dir, "responses", key[:2], key+".json")
+}
+
+func (c *CachingClient) read(key string) (cacheEntry, bool) {
+	var entry cacheEntry
+	b, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return entry, false
+	}
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+// write atomically replaces the entry for a key, so that concurrent
+// invocations never read a partially-written file.
+func (c *CachingClient) write(key string, entry cacheEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,223 @@
+package nixsearch
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+// fakeClient returns a fixed set of packages, or a fixed error, and counts
+// how many times it was asked to search.
+type fakeClient struct {
+	packages []Package
+	err      error
+	calls    int
+}
+
+func (f *fakeClient) Search(ctx context.Context, query Query) ([]Package, error) {
+	result, err := f.SearchWithMeta(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return result.Packages(), nil
+}
+
+func (f *fakeClient) SearchWithMeta(_ context.Context, _ Query) (SearchResult, error) {
+	f.calls++
+	if f.err != nil {
+		return SearchResult{}, f.err
+	}
+	result := SearchResult{Total: len(f.packages)}
+	for _, pkg := range f.packages {
+		result.Hits = append(result.Hits, PackageHit{Package: pkg, Score: 1})
+	}
+	return result, nil
+}
+
+func (f *fakeClient) SearchAll(ctx context.Context, query Query) iter.Seq2[Package, error] {
+	return func(yield func(Package, error) bool) {
+		packages, err := f.Search(ctx, query)
+		if err != nil {
+			yield(Package{}, err)
+			return
+		}
+		for _, pkg := range packages {
+			if !yield(pkg, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestCachingClientReusesResponses(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	inner := &fakeClient{packages: []Package{{AttrName: "ripgrep", Version: "14.1.0"}}}
+	client, err := NewCachingClient(inner, CacheOptions{Dir: t.TempDir()})
+	assert.NoError(t, err)
+
+	query := Query{Channel: "unstable", MaxResults: 10, Program: &MatchProgram{Program: "rg"}}
+	for range 3 {
+		packages, err := client.Search(ctx, query)
+		assert.NoError(t, err)
+		check.Equal(t, inner.packages, packages)
+	}
+	check.Equal(t, 1, inner.calls)
+
+	// A different channel is a different index, and so a different entry.
+	query.Channel = "24.05"
+	_, err = client.Search(ctx, query)
+	assert.NoError(t, err)
+	check.Equal(t, 2, inner.calls)
+
+	// As is any other difference in the payload.
+	query.MaxResults = 11
+	_, err = client.Search(ctx, query)
+	assert.NoError(t, err)
+	check.Equal(t, 3, inner.calls)
+}
+
+func TestCachingClientStaleIfError(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	inner := &fakeOptionClient{
+		fakeClient: fakeClient{packages: []Package{{AttrName: "ripgrep", Version: "14.1.0"}}},
+		options:    []Option{{Name: "services.nginx.enable"}},
+		channels:   []Channel{{Name: "unstable", Index: "latest-43-nixos-unstable"}},
+	}
+	var staleIndexes []string
+	var staleErrs []error
+	client, err := NewCachingClient(inner, CacheOptions{
+		Dir: t.TempDir(),
+		TTL: time.Nanosecond,
+		OnStale: func(index string, _ time.Time, err error) {
+			staleIndexes = append(staleIndexes, index)
+			staleErrs = append(staleErrs, err)
+		},
+	})
+	assert.NoError(t, err)
+
+	query := Query{Channel: "unstable", MaxResults: 10, Name: &MatchName{Name: "ripgrep"}}
+	_, err = client.Search(ctx, query)
+	assert.NoError(t, err)
+	optionQuery := OptionQuery{Channel: "unstable", MaxResults: 10, Name: "services.nginx."}
+	_, err = client.SearchOptions(ctx, optionQuery)
+	assert.NoError(t, err)
+	_, err = client.ListChannels(ctx)
+	assert.NoError(t, err)
+
+	// Without StaleIfError, an expired entry is never returned.
+	offline := errors.New("offline")
+	inner.err = offline
+	_, err = client.Search(ctx, query)
+	check.True(t, errors.Is(err, offline))
+	_, err = client.SearchOptions(ctx, optionQuery)
+	check.True(t, errors.Is(err, offline))
+	_, err = client.ListChannels(ctx)
+	check.True(t, errors.Is(err, offline))
+	check.Equal(t, 0, len(staleErrs))
+
+	// With it, the expired entry is returned and the hook is told why.
+	client.StaleIfError = true
+	packages, err := client.Search(ctx, query)
+	assert.NoError(t, err)
+	check.Equal(t, inner.packages, packages)
+	assert.Equal(t, 1, len(staleErrs))
+	check.True(t, errors.Is(staleErrs[0], offline))
+
+	result, err := client.SearchWithMeta(ctx, query)
+	assert.NoError(t, err)
+	check.True(t, result.Stale)
+
+	// Options and channels are marked as stale, and the hook is told about
+	// them too.
+	options, err := client.SearchOptions(ctx, optionQuery)
+	assert.NoError(t, err)
+	check.Equal(t, []Option{{Name: "services.nginx.enable", Stale: true}}, options)
+	channels, err := client.ListChannels(ctx)
+	assert.NoError(t, err)
+	check.Equal(t, []Channel{{Name: "unstable", Index: "latest-43-nixos-unstable", Stale: true}}, channels)
+	check.Equal(t, []string{
+		"nixos-unstable",
+		"nixos-unstable",
+		"nixos-unstable",
+		"_aliases",
+	}, staleIndexes)
+	for _, err := range staleErrs {
+		check.True(t, errors.Is(err, offline))
+	}
+
+	// Nothing is returned for queries that were never cached.
+	query.Name.Name = "fd"
+	_, err = client.Search(ctx, query)
+	check.True(t, errors.Is(err, offline))
+}
+
+func TestCachingClientTTL(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewCachingClient(&fakeClient{}, CacheOptions{Dir: t.TempDir()})
+	assert.NoError(t, err)
+	check.Equal(t, DefaultCacheTTL, client.TTL)
+
+	_, err = NewCachingClient(&fakeClient{}, CacheOptions{Dir: t.TempDir(), TTL: -time.Minute})
+	check.Error(t, err)
+}
+
+// fakeOptionClient is a fakeClient that can also search for options and
+// list channels.
+type fakeOptionClient struct {
+	fakeClient
+	options     []Option
+	optionCalls int
+	channels    []Channel
+}
+
+func (f *fakeOptionClient) SearchOptions(_ context.Context, _ OptionQuery) ([]Option, error) {
+	f.optionCalls++
+	return f.options, f.err
+}
+
+func (f *fakeOptionClient) ListChannels(_ context.Context) ([]Channel, error) {
+	return f.channels, f.err
+}
+
+func TestCachingClientSearchOptions(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	inner := &fakeOptionClient{options: []Option{{Name: "services.nginx.enable"}}}
+	client, err := NewCachingClient(inner, CacheOptions{Dir: t.TempDir()})
+	assert.NoError(t, err)
+
+	query := OptionQuery{Channel: "unstable", MaxResults: 10, Name: "services.nginx."}
+	for range 2 {
+		options, err := client.SearchOptions(ctx, query)
+		assert.NoError(t, err)
+		check.Equal(t, inner.options, options)
+	}
+	check.Equal(t, 1, inner.optionCalls)
+
+	// Searches that found nothing are cached too.
+	inner.options = nil
+	query.Name = "services.bogus."
+	for range 2 {
+		options, err := client.SearchOptions(ctx, query)
+		assert.NoError(t, err)
+		check.Equal(t, 0, len(options))
+	}
+	check.Equal(t, 2, inner.optionCalls)
+
+	// Clients that can't search options are reported as such.
+	client, err = NewCachingClient(&fakeClient{}, CacheOptions{Dir: t.TempDir()})
+	assert.NoError(t, err)
+	_, err = client.SearchOptions(ctx, query)
+	check.Error(t, err)
+}
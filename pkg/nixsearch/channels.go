@@ -0,0 +1,342 @@
+package nixsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Channel is a nixpkgs channel that can be searched, like "unstable" or
+// "24.05".
+type Channel struct {
+	// Name is the value to use as [Query.Channel].
+	Name string `json:"name"`
+	// Index is the name of the index that holds the channel's packages,
+	// like "latest-43-nixos-unstable".
+	Index string `json:"index"`
+	// Generation is the version number of the index's schema, the 43 in
+	// "latest-43-nixos-unstable". It increases every time the upstream
+	// project re-creates all of its indexes.
+	Generation int `json:"generation"`
+	// Packages is the number of packages in the channel.
+	Packages int `json:"packages"`
+	// Stale is set by a [CachingClient] when the channel comes from an
+	// expired cache entry, returned because listing the channels failed.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// ChannelLister is implemented by clients that can discover which channels
+// are available to search.
+type ChannelLister interface {
+	ListChannels(ctx context.Context) ([]Channel, error)
+}
+
+// ListChannels returns every channel that can be searched, discovered from the
+// index aliases on the cluster. When there is more than one generation of a
+// channel's index, only the newest is returned. Channels are sorted with
+// unstable first and then from the newest release to the oldest.
+func (c ElasticSearchClient) ListChannels(ctx context.Context) ([]Channel, error) {
+	channels, concrete, err := c.discoverChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := c.countPackages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, channel := range channels {
+		channels[i].Packages = counts[concrete[channel.Index]]
+	}
+	return channels, nil
+}
+
+// discoverChannels returns every channel on the cluster, without package
+// counts, and a mapping from each channel's index to the concrete index that
+// it is an alias of.
+func (c ElasticSearchClient) discoverChannels(ctx context.Context) ([]Channel, map[string]string, error) {
+	var aliases map[string]struct {
+		Aliases map[string]any `json:"aliases"`
+	}
+	if err := c.doInto(ctx, http.MethodGet, "/_aliases", nil, &aliases); err != nil {
+		return nil, nil, err
+	}
+
+	// Index names look like "<prefix>nixos-<channel>", where a "*" in the
+	// prefix stands for the generation number.
+	pattern := regexp.QuoteMeta(c.IndexPrefix)
+	pattern = strings.Replace(pattern, `\*`, `(\d+)`, 1)
+	if !strings.Contains(pattern, `(\d+)`) {
+		pattern += "()"
+	}
+	re := regexp.MustCompile("^" + pattern + "nixos-(.+)$")
+
+	byName := map[string]Channel{}
+	concrete := map[string]string{} // channel index => concrete index
+	for index, entry := range aliases {
+		// Concrete index names are only considered if they have no aliases,
+		// for clusters that don't use aliases at all.
+		names := []string{index}
+		if len(entry.Aliases) != 0 {
+			names = names[:0]
+			for alias := range entry.Aliases {
+				names = append(names, alias)
+			}
+		}
+		for _, name := range names {
+			m := re.FindStringSubmatch(name)
+			if m == nil {
+				continue
+			}
+			generation, _ := strconv.Atoi(m[1])
+			channel := Channel{Name: m[2], Index: name, Generation: generation}
+			if existing, ok := byName[channel.Name]; ok && existing.Generation >= channel.Generation {
+				continue
+			}
+			byName[channel.Name] = channel
+			concrete[channel.Index] = index
+		}
+	}
+
+	channels := make([]Channel, 0, len(byName))
+	for _, channel := range byName {
+		channels = append(channels, channel)
+	}
+	SortChannels(channels)
+	return channels, concrete, nil
+}
+
+// resolveChannel resolves a symbolic channel name using the channels on the
+// cluster. Concrete channel names are returned as-is, without any requests.
+func (c ElasticSearchClient) resolveChannel(ctx context.Context, name string) (string, error) {
+	if !IsSymbolicChannel(name) {
+		return name, nil
+	}
+	channels, _, err := c.discoverChannels(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ResolveChannel(channels, name)
+}
+
+// countPackages returns the number of packages in each concrete channel
+// index, using a single aggregation over all of them.
+func (c ElasticSearchClient) countPackages(ctx context.Context) (map[string]int, error) {
+	body, err := json.Marshal(Dict{
+		"size": 0,
+		"query": Dict{
+			"match": Dict{
+				"type": "package",
+			},
+		},
+		"aggs": Dict{
+			"indexes": Dict{
+				"terms": Dict{
+					"field": "_index",
+					"size":  1000,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var r struct {
+		Aggregations struct {
+			Indexes struct {
+				Buckets []struct {
+					Key      string `json:"key"`
+					DocCount int    `json:"doc_count"`
+				} `json:"buckets"`
+			} `json:"indexes"`
+		} `json:"aggregations"`
+	}
+	path := c.indexPath("nixos-*") + "/_search"
+	if err := c.doInto(ctx, http.MethodPost, path, body, &r); err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, bucket := range r.Aggregations.Indexes.Buckets {
+		counts[bucket.Key] = bucket.DocCount
+	}
+	return counts, nil
+}
+
+// ListChannels returns the channels that have been synced to the mirror.
+func (c *LocalClient) ListChannels(_ context.Context) ([]Channel, error) {
+	infos, err := c.Mirror.List()
+	if err != nil {
+		return nil, err
+	}
+	var channels []Channel
+	for _, info := range infos {
+		name, ok := strings.CutPrefix(info.Index, "nixos-")
+		if !ok {
+			continue
+		}
+		channels = append(channels, Channel{
+			Name:     name,
+			Index:    info.Index,
+			Packages: info.Packages,
+		})
+	}
+	SortChannels(channels)
+	return channels, nil
+}
+
+// resolveChannel resolves a symbolic channel name using the channels that
+// have been synced to the mirror.
+func (c *LocalClient) resolveChannel(ctx context.Context, name string) (string, error) {
+	if !IsSymbolicChannel(name) {
+		return name, nil
+	}
+	channels, err := c.ListChannels(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ResolveChannel(channels, name)
+}
+
+// SortChannels sorts channels with "unstable" first, then release channels
+// from newest to oldest, then anything else alphabetically.
+func SortChannels(channels []Channel) {
+	sort.SliceStable(channels, func(i, j int) bool {
+		return compareChannelNames(channels[i].Name, channels[j].Name) < 0
+	})
+}
+
+func compareChannelNames(a, b string) int {
+	rank := func(name string) int {
+		if name == "unstable" {
+			return 0
+		}
+		if _, ok := parseRelease(name); ok {
+			return 1
+		}
+		return 2
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+	ra, aok := parseRelease(a)
+	rb, bok := parseRelease(b)
+	if aok && bok && ra != rb {
+		return rb - ra
+	}
+	return strings.Compare(a, b)
+}
+
+// parseRelease parses a release channel name like "24.05" into a number
+// that sorts in release order (2405).
+func parseRelease(name string) (int, bool) {
+	year, month, ok := strings.Cut(name, ".")
+	if !ok || len(month) != 2 {
+		return 0, false
+	}
+	y, err := strconv.Atoi(year)
+	if err != nil {
+		return 0, false
+	}
+	m, err := strconv.Atoi(month)
+	if err != nil {
+		return 0, false
+	}
+	return y*100 + m, true
+}
+
+// Symbolic channel names, which refer to different release channels over
+// time. See [ResolveChannel].
+const (
+	// ChannelStable is the newest release, like "24.05".
+	ChannelStable = "stable"
+	// ChannelOldStable is the release before [ChannelStable], which is
+	// still supported for a month after each new release.
+	ChannelOldStable = "oldstable"
+	// ChannelPreviousStable is another name for [ChannelOldStable].
+	ChannelPreviousStable = "previous-stable"
+)
+
+// IsSymbolicChannel reports whether name is one of the symbolic channel
+// names that must be resolved with [ResolveChannel] before searching.
+func IsSymbolicChannel(name string) bool {
+	switch name {
+	case ChannelStable, ChannelOldStable, ChannelPreviousStable:
+		return true
+	default:
+		return false
+	}
+}
+
+// ResolveChannel returns the name of the channel that a symbolic channel name
+// currently refers to, by sorting the release channels by version. Any other
+// name, including "unstable", is returned unchanged.
+func ResolveChannel(channels []Channel, name string) (string, error) {
+	if !IsSymbolicChannel(name) {
+		return name, nil
+	}
+	var releases []Channel
+	for _, channel := range channels {
+		if _, ok := parseRelease(channel.Name); ok {
+			releases = append(releases, channel)
+		}
+	}
+	SortChannels(releases)
+	n := 0
+	if name != ChannelStable {
+		n = 1
+	}
+	if n >= len(releases) {
+		return "", fmt.Errorf("can not resolve channel %q: found %d release channels", name, len(releases))
+	}
+	return releases[n].Name, nil
+}
+
+// UnknownChannelError is returned by [ValidateChannel] when a channel isn't
+// one of the channels that can be searched.
+type UnknownChannelError struct {
+	Channel    string
+	Suggestion string   // The closest available channel, if any are close.
+	Available  []string // Every channel that can be searched.
+}
+
+func (e UnknownChannelError) Error() string {
+	msg := fmt.Sprintf("unknown channel %q", e.Channel)
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", e.Suggestion)
+	}
+	if len(e.Available) != 0 {
+		msg += fmt.Sprintf(" (available channels: %s)", strings.Join(e.Available, ", "))
+	}
+	return msg
+}
+
+// Is makes an UnknownChannelError match [ErrChannelNotFound].
+func (e UnknownChannelError) Is(target error) bool {
+	return target == ErrChannelNotFound
+}
+
+// ValidateChannel returns an [UnknownChannelError] if name is not the name of
+// one of the channels, or an error if it is a symbolic name that doesn't
+// resolve to any of them.
+func ValidateChannel(channels []Channel, name string) error {
+	if IsSymbolicChannel(name) {
+		_, err := ResolveChannel(channels, name)
+		return err
+	}
+	available := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		if channel.Name == name {
+			return nil
+		}
+		available = append(available, channel.Name)
+	}
+	return UnknownChannelError{
+		Channel:    name,
+		Suggestion: closest(name, append(available, ChannelStable, ChannelOldStable)),
+		Available:  available,
+	}
+}
@@ -0,0 +1,116 @@
+package nixsearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+func TestListChannels(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewElasticSearchClientWithOptions(ClientOptions{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			switch req.URL.Path {
+			case "/_aliases":
+				return jsonResponse(t, http.StatusOK, Dict{
+					"nixos-42-unstable-aaa": Dict{"aliases": Dict{"latest-42-nixos-unstable": Dict{}}},
+					"nixos-43-unstable-bbb": Dict{"aliases": Dict{"latest-43-nixos-unstable": Dict{}}},
+					"nixos-43-24.05-ccc":    Dict{"aliases": Dict{"latest-43-nixos-24.05": Dict{}}},
+					"nixos-43-24.11-ddd":    Dict{"aliases": Dict{"latest-43-nixos-24.11": Dict{}}},
+					"nixos-43-flakes-eee":   Dict{"aliases": Dict{"latest-43-group-manual": Dict{}}},
+					".kibana":               Dict{"aliases": Dict{}},
+				}), nil
+			case "/latest-*-nixos-*/_search":
+				return jsonResponse(t, http.StatusOK, Dict{
+					"aggregations": Dict{
+						"indexes": Dict{
+							"buckets": []Dict{
+								{"key": "nixos-43-unstable-bbb", "doc_count": 120001},
+								{"key": "nixos-42-unstable-aaa", "doc_count": 110001},
+								{"key": "nixos-43-24.05-ccc", "doc_count": 100001},
+								{"key": "nixos-43-24.11-ddd", "doc_count": 110002},
+							},
+						},
+					},
+				}), nil
+			default:
+				t.Fatalf("unexpected request to %s", req.URL)
+				return nil, nil
+			}
+		}),
+	})
+	assert.NoError(t, err)
+
+	channels, err := client.ListChannels(context.Background())
+	assert.NoError(t, err)
+	check.Equal(t, []Channel{
+		{Name: "unstable", Index: "latest-43-nixos-unstable", Generation: 43, Packages: 120001},
+		{Name: "24.11", Index: "latest-43-nixos-24.11", Generation: 43, Packages: 110002},
+		{Name: "24.05", Index: "latest-43-nixos-24.05", Generation: 43, Packages: 100001},
+	}, channels)
+}
+
+func TestValidateChannel(t *testing.T) {
+	t.Parallel()
+
+	channels := []Channel{{Name: "unstable"}, {Name: "24.11"}, {Name: "24.05"}}
+	check.Nil(t, ValidateChannel(channels, "24.05"))
+
+	var unknown UnknownChannelError
+	err := ValidateChannel(channels, "24.5")
+	assert.True(t, errors.As(err, &unknown))
+	check.Equal(t, "24.05", unknown.Suggestion)
+	check.Equal(t, []string{"unstable", "24.11", "24.05"}, unknown.Available)
+	check.Equal(t, `unknown channel "24.5", did you mean "24.05"? (available channels: unstable, 24.11, 24.05)`, err.Error())
+
+	err = ValidateChannel(channels, "unstabel")
+	assert.True(t, errors.As(err, &unknown))
+	check.Equal(t, "unstable", unknown.Suggestion)
+
+	err = ValidateChannel(channels, "stabel")
+	assert.True(t, errors.As(err, &unknown))
+	check.Equal(t, "stable", unknown.Suggestion)
+	check.Nil(t, ValidateChannel(channels, "stable"))
+
+	// Nothing is suggested if nothing is close.
+	err = ValidateChannel(channels, "nixpkgs-master")
+	assert.True(t, errors.As(err, &unknown))
+	check.Equal(t, "", unknown.Suggestion)
+}
+
+func TestSortChannels(t *testing.T) {
+	t.Parallel()
+
+	channels := []Channel{{Name: "23.11"}, {Name: "staging"}, {Name: "24.05"}, {Name: "unstable"}, {Name: "9.03"}}
+	SortChannels(channels)
+	var names []string
+	for _, channel := range channels {
+		names = append(names, channel.Name)
+	}
+	check.Equal(t, []string{"unstable", "24.05", "23.11", "9.03", "staging"}, names)
+}
+
+func TestResolveChannel(t *testing.T) {
+	t.Parallel()
+
+	channels := []Channel{{Name: "unstable"}, {Name: "23.11"}, {Name: "24.11"}, {Name: "24.05"}}
+	for name, want := range map[string]string{
+		"stable":          "24.11",
+		"oldstable":       "24.05",
+		"previous-stable": "24.05",
+		"unstable":        "unstable",
+		"23.11":           "23.11",
+	} {
+		got, err := ResolveChannel(channels, name)
+		check.NoError(t, err)
+		check.Equal(t, want, got)
+	}
+
+	_, err := ResolveChannel([]Channel{{Name: "unstable"}, {Name: "24.05"}}, "oldstable")
+	check.Error(t, err)
+}
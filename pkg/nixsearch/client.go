@@ -2,8 +2,50 @@ package nixsearch
 
 import (
 	"context"
+	"iter"
 )
 
 type Client interface {
 	Search(ctx context.Context, query Query) ([]Package, error)
+	// SearchWithMeta is like Search, but also returns the relevance and
+	// source index of each package, and how many packages matched in total.
+	SearchWithMeta(ctx context.Context, query Query) (SearchResult, error)
+	// SearchAll returns every package matching the query, fetching them one
+	// page at a time. query.MaxResults is used as the page size.
+	SearchAll(ctx context.Context, query Query) iter.Seq2[Package, error]
+}
+
+// SearchResult is a single page of search results, along with metadata about
+// each of them and about the search as a whole.
+type SearchResult struct {
+	Hits []PackageHit `json:"hits"`
+	// Total is how many packages matched the query, across every page.
+	Total int `json:"total"`
+	// TotalIsLowerBound is set when ElasticSearch stopped counting matches
+	// early, and there are at least Total of them.
+	TotalIsLowerBound bool `json:"total_is_lower_bound,omitempty"`
+	// Stale is set by a [CachingClient] when the result is an expired cache
+	// entry, returned because the search itself failed.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// Packages returns the package of each hit.
+func (r SearchResult) Packages() []Package {
+	var packages []Package
+	for _, hit := range r.Hits {
+		packages = append(packages, hit.Package)
+	}
+	return packages
+}
+
+// PackageHit is a package in a [SearchResult].
+type PackageHit struct {
+	Package
+	// Score is how relevant the package is to the query; higher is better.
+	Score float64 `json:"score,omitempty"`
+	// Index is the name of the concrete index the package was found in.
+	Index string `json:"index,omitempty"`
+	// Highlights are the fragments of each field that matched the query,
+	// keyed by field name, with the matching terms wrapped in <em> tags.
+	Highlights map[string][]string `json:"highlights,omitempty"`
 }
@@ -0,0 +1,129 @@
+package nixsearch
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixversion"
+)
+
+// DefaultCompareWorkers is how many channels [CompareChannels] searches at
+// once, unless told otherwise.
+const DefaultCompareWorkers = 4
+
+// VersionMatrix is the version of each of a set of packages in each of a set
+// of channels, as found by [CompareChannels].
+type VersionMatrix struct {
+	AttrNames []string
+	Channels  []string
+	// Versions maps each attr name to a map of each channel to the version
+	// of the package in that channel. Channels that don't have the package
+	// have no entry.
+	Versions map[string]map[string]string
+}
+
+// Version returns the version of the package in the channel, or false if the
+// channel doesn't have the package.
+func (m VersionMatrix) Version(attrName, channel string) (string, bool) {
+	version, ok := m.Versions[attrName][channel]
+	return version, ok
+}
+
+// CompareChannels finds the version of each package in each of the channels,
+// by searching every channel for the exact attr names. Up to workers channels
+// are searched at once. If any search fails, the others are cancelled and the
+// first error is returned.
+//
+// If a channel has more than one version of a package, because the package
+// is in more than one generation of the index, the newest version is used.
+func CompareChannels(ctx context.Context, client Client, attrNames, channels []string, workers int) (VersionMatrix, error) {
+	matrix := VersionMatrix{
+		AttrNames: attrNames,
+		Channels:  channels,
+		Versions:  map[string]map[string]string{},
+	}
+	for _, attrName := range attrNames {
+		matrix.Versions[attrName] = map[string]string{}
+	}
+	if len(attrNames) == 0 || len(channels) == 0 {
+		return matrix, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	for range min(max(workers, 1), len(channels)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for channel := range jobs {
+				if ctx.Err() != nil {
+					continue // drain the remaining jobs after a failure
+				}
+				versions, err := channelVersions(ctx, client, attrNames, channel)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				for attrName, version := range versions {
+					matrix.Versions[attrName][channel] = version
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+sendJobs:
+	for _, channel := range channels {
+		select {
+		case jobs <- channel:
+		case <-ctx.Done():
+			break sendJobs
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return VersionMatrix{}, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return VersionMatrix{}, err
+	}
+	return matrix, nil
+}
+
+// channelVersions returns the newest version of each of the packages that is
+// in the channel.
+func channelVersions(ctx context.Context, client Client, attrNames []string, channel string) (map[string]string, error) {
+	query := Query{
+		Channel: channel,
+		// Leave room for the same package in more than one index.
+		MaxResults: 4 * len(attrNames),
+		AttrNames:  &MatchAttrNames{AttrNames: attrNames},
+	}
+	packages, err := client.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	versions := map[string]string{}
+	for _, pkg := range packages {
+		if !slices.Contains(attrNames, pkg.AttrName) {
+			continue
+		}
+		addNewestVersion(versions, pkg)
+	}
+	return versions, nil
+}
+
+// addNewestVersion records the version of the package, unless a newer
+// version of it has already been recorded.
+func addNewestVersion(versions map[string]string, pkg Package) {
+	if version, ok := versions[pkg.AttrName]; !ok || nixversion.Compare(pkg.Version, version) > 0 {
+		versions[pkg.AttrName] = pkg.Version
+	}
+}
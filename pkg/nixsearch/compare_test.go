@@ -0,0 +1,56 @@
+package nixsearch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+func TestCompareChannels(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	client, err := NewLocalClient(t.TempDir())
+	assert.NoError(t, err)
+	_, err = client.Mirror.Write("nixos-unstable", packageSeq(
+		Package{Type: "package", AttrName: "go", Version: "1.22.5"},
+		Package{Type: "package", AttrName: "go_1_23", Version: "1.23.0"},
+		Package{Type: "package", AttrName: "gopls", Version: "0.16.1"},
+	))
+	assert.NoError(t, err)
+	_, err = client.Mirror.Write("nixos-24.05", packageSeq(
+		Package{Type: "package", AttrName: "go", Version: "1.22.3"},
+	))
+	assert.NoError(t, err)
+
+	matrix, err := CompareChannels(ctx, client, []string{"go", "go_1_23"}, []string{"unstable", "24.05"}, 2)
+	assert.NoError(t, err)
+	check.Equal(t, map[string]map[string]string{
+		"go":      {"unstable": "1.22.5", "24.05": "1.22.3"},
+		"go_1_23": {"unstable": "1.23.0"},
+	}, matrix.Versions)
+	version, ok := matrix.Version("go_1_23", "unstable")
+	check.True(t, ok)
+	check.Equal(t, "1.23.0", version)
+	_, ok = matrix.Version("go_1_23", "24.05")
+	check.False(t, ok)
+}
+
+func TestCompareChannelsError(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	inner := &fakeClient{err: errors.New("search failed")}
+	_, err := CompareChannels(ctx, inner, []string{"go"}, []string{"unstable", "24.05", "23.11"}, 1)
+	check.Error(t, err)
+	// The first failure stops any channels that haven't been searched yet.
+	check.Equal(t, 1, inner.calls)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = CompareChannels(cancelled, &fakeClient{}, []string{"go"}, []string{"unstable"}, 1)
+	check.True(t, errors.Is(err, context.Canceled))
+}
@@ -1,5 +1,15 @@
 package nixsearch
 
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixversion"
+)
+
 // Deduplicate filters a slice of [Package] objects to remove any duplicates
 // that have the same ID (attr name). Notably, this does not sort the input list
 // of packages, so if there are multiple entries with the same ID, the result
@@ -9,17 +19,144 @@ package nixsearch
 // sometimes read results from multiple different elasticsearch indexes, which
 // means the same package can appear more than once in the list of results.
 // Although the duplicate entries may have different versions, metadata, etc.
-// this deduplication filter just returns whichever one shows up first.
+// this deduplication filter just returns whichever one shows up first. To
+// keep the newest version instead, use [DeduplicateHitsBy].
 func Deduplicate(packages []Package) []Package {
-	seen := map[string]struct{}{}
-	var deduped []Package
-	for _, pkg := range packages {
-		id := pkg.ID()
-		if _, ok := seen[id]; ok {
+	return deduplicate(packages, Package.ID)
+}
+
+// DeduplicateHits is the same as [Deduplicate], for the hits of a
+// [SearchResult].
+func DeduplicateHits(hits []PackageHit) []PackageHit {
+	return DeduplicateHitsBy(hits, DedupeFirst)
+}
+
+func deduplicate[T any](items []T, id func(T) string) []T {
+	var deduped []T
+	for _, group := range groupBy(items, id) {
+		deduped = append(deduped, group[0])
+	}
+	return deduped
+}
+
+// groupBy groups items with the same ID together, in the order that each ID
+// first appears.
+func groupBy[T any](items []T, id func(T) string) [][]T {
+	positions := map[string]int{}
+	var groups [][]T
+	for _, item := range items {
+		key := id(item)
+		if i, ok := positions[key]; ok {
+			groups[i] = append(groups[i], item)
 			continue
 		}
-		seen[id] = struct{}{}
-		deduped = append(deduped, pkg)
+		positions[key] = len(groups)
+		groups = append(groups, []T{item})
+	}
+	return groups
+}
+
+// DedupeStrategy decides which of the duplicate entries for a package is kept
+// by [DeduplicateHitsBy].
+type DedupeStrategy string
+
+const (
+	// DedupeFirst keeps whichever duplicate appears first, which is the most
+	// relevant one.
+	DedupeFirst DedupeStrategy = "first"
+	// DedupeNewestVersion keeps the duplicate with the newest version, using
+	// the same ordering as Nix (see [nixversion.Compare]).
+	DedupeNewestVersion DedupeStrategy = "newest-version"
+	// DedupeNewestIndex keeps the duplicate that came from the newest
+	// generation of the index, like "latest-43-nixos-unstable" over
+	// "latest-42-nixos-unstable". Hits without an index count as the oldest.
+	DedupeNewestIndex DedupeStrategy = "newest-index"
+	// DedupeMerge keeps the duplicate with the newest version, like
+	// DedupeNewestVersion, and adds the programs, platforms, outputs,
+	// homepages, and maintainers of every other duplicate to it.
+	DedupeMerge DedupeStrategy = "merge"
+)
+
+// DedupeStrategies lists every [DedupeStrategy].
+var DedupeStrategies = []DedupeStrategy{ //nolint:gochecknoglobals
+	DedupeFirst,
+	DedupeNewestVersion,
+	DedupeNewestIndex,
+	DedupeMerge,
+}
+
+// ParseDedupeStrategy returns the [DedupeStrategy] with the given name.
+func ParseDedupeStrategy(name string) (DedupeStrategy, error) {
+	strategy := DedupeStrategy(name)
+	if !slices.Contains(DedupeStrategies, strategy) {
+		names := make([]string, 0, len(DedupeStrategies))
+		for _, s := range DedupeStrategies {
+			names = append(names, string(s))
+		}
+		return "", fmt.Errorf("unknown dedupe strategy %q, expected one of: %s", name, strings.Join(names, ", "))
+	}
+	return strategy, nil
+}
+
+// DeduplicateHitsBy removes any hits for the same package (see [Package.ID]),
+// using the strategy to decide which of them to keep. The hits stay in the
+// same order, with each package in the position of its first hit. Unknown
+// strategies are treated as [DedupeFirst].
+func DeduplicateHitsBy(hits []PackageHit, strategy DedupeStrategy) []PackageHit {
+	var deduped []PackageHit
+	for _, group := range groupBy(hits, func(hit PackageHit) string { return hit.ID() }) {
+		deduped = append(deduped, pickHit(group, strategy))
 	}
 	return deduped
 }
+
+func pickHit(group []PackageHit, strategy DedupeStrategy) PackageHit {
+	byVersion := func(a, b PackageHit) int { return nixversion.Compare(a.Version, b.Version) }
+	switch strategy {
+	case DedupeNewestVersion:
+		return slices.MaxFunc(group, byVersion)
+	case DedupeNewestIndex:
+		return slices.MaxFunc(group, func(a, b PackageHit) int {
+			return indexGeneration(a.Index) - indexGeneration(b.Index)
+		})
+	case DedupeMerge:
+		merged := slices.MaxFunc(group, byVersion)
+		for _, hit := range group {
+			merged.Programs = union(merged.Programs, hit.Programs)
+			merged.Platforms = union(merged.Platforms, hit.Platforms)
+			merged.Outputs = union(merged.Outputs, hit.Outputs)
+			merged.Homepage = union(merged.Homepage, hit.Homepage)
+			merged.MaintainersSet = union(merged.MaintainersSet, hit.MaintainersSet)
+			for _, maintainer := range hit.Maintainers {
+				if !slices.Contains(merged.Maintainers, maintainer) {
+					merged.Maintainers = append(slices.Clip(merged.Maintainers), maintainer)
+				}
+			}
+		}
+		return merged
+	default:
+		return group[0]
+	}
+}
+
+// union returns a with any values of b that it doesn't already contain added
+// to the end. a is never modified in place.
+func union(a, b []string) []string {
+	out := a
+	for _, x := range b {
+		if !slices.Contains(out, x) {
+			out = append(slices.Clip(out), x)
+		}
+	}
+	return out
+}
+
+var indexGenerationPattern = regexp.MustCompile(`\d+`) //nolint:gochecknoglobals
+
+// indexGeneration returns the generation of an index, which is the first
+// number in its name: 43 for "latest-43-nixos-unstable" and
+// "nixos-43-unstable-...". Indexes without one are generation 0.
+func indexGeneration(index string) int {
+	generation, _ := strconv.Atoi(indexGenerationPattern.FindString(index))
+	return generation
+}
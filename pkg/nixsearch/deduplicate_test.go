@@ -90,3 +90,58 @@ func TestDeduplicateFlakeAttrnames(t *testing.T) {
 	pkgs := []Package{pkgFlake, notADuplicate}
 	check.Equal(t, pkgs, Deduplicate(pkgs))
 }
+
+func TestDeduplicateHitsBy(t *testing.T) {
+	t.Parallel()
+
+	older := PackageHit{
+		Package: Package{
+			AttrName:  "go",
+			Version:   "1.9.7",
+			Programs:  []string{"go", "gofmt"},
+			Platforms: []string{"x86_64-linux"},
+		},
+		Index: "nixos-43-unstable-abc",
+	}
+	newer := PackageHit{
+		Package: Package{
+			AttrName:  "go",
+			Version:   "1.10.1", // newer, although it sorts first as a string
+			Programs:  []string{"go"},
+			Platforms: []string{"aarch64-darwin"},
+		},
+		Index: "nixos-42-unstable-def",
+	}
+	other := PackageHit{Package: Package{AttrName: "gopls", Version: "0.16.2"}}
+	hits := []PackageHit{older, other, newer}
+
+	check.Equal(t, []PackageHit{older, other}, DeduplicateHitsBy(hits, DedupeFirst))
+	check.Equal(t, []PackageHit{newer, other}, DeduplicateHitsBy(hits, DedupeNewestVersion))
+	check.Equal(t, []PackageHit{older, other}, DeduplicateHitsBy(hits, DedupeNewestIndex))
+
+	merged := DeduplicateHitsBy(hits, DedupeMerge)
+	check.Equal(t, 2, len(merged))
+	check.Equal(t, "1.10.1", merged[0].Version)
+	check.Equal(t, "nixos-42-unstable-def", merged[0].Index)
+	check.Equal(t, []string{"go", "gofmt"}, merged[0].Programs)
+	check.Equal(t, []string{"aarch64-darwin", "x86_64-linux"}, merged[0].Platforms)
+	// The hits that were merged are left as they were.
+	check.Equal(t, []string{"go"}, newer.Programs)
+
+	// Ties keep whichever appears first.
+	tied := newer
+	tied.Index = older.Index
+	check.Equal(t, []PackageHit{newer}, DeduplicateHitsBy([]PackageHit{newer, tied}, DedupeNewestVersion))
+}
+
+func TestParseDedupeStrategy(t *testing.T) {
+	t.Parallel()
+
+	for _, strategy := range DedupeStrategies {
+		parsed, err := ParseDedupeStrategy(string(strategy))
+		check.NoError(t, err)
+		check.Equal(t, strategy, parsed)
+	}
+	_, err := ParseDedupeStrategy("newest")
+	check.Error(t, err)
+}
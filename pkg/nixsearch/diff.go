@@ -0,0 +1,158 @@
+package nixsearch
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixversion"
+)
+
+// diffPageSize is how many packages [DiffChannels] fetches per request.
+const diffPageSize = 1000
+
+// ChangeKind is how a package changed between two channels.
+type ChangeKind string
+
+const (
+	Added      ChangeKind = "added"
+	Removed    ChangeKind = "removed"
+	Upgraded   ChangeKind = "upgraded"
+	Downgraded ChangeKind = "downgraded"
+)
+
+// PackageChange is a package that is different in two channels.
+type PackageChange struct {
+	AttrName string     `json:"attr_name"`
+	Kind     ChangeKind `json:"change"`
+	// From is the version of the package in the old channel, or "" if it
+	// was added.
+	From string `json:"from,omitempty"`
+	// To is the version of the package in the new channel, or "" if it was
+	// removed.
+	To string `json:"to,omitempty"`
+	// MajorBump is set when the first component of the version changed,
+	// like "1.9.2" to "2.0.0". It's never set for versions that aren't
+	// numbered that way, like dates or "unstable-2024-01-01".
+	MajorBump bool `json:"major_bump,omitempty"`
+}
+
+// ChannelDiff is every package that was added, removed, upgraded, or
+// downgraded between two channels, as found by [DiffChannels].
+type ChannelDiff struct {
+	From string
+	To   string
+	// Changes are sorted by attr name.
+	Changes []PackageChange
+	// Unchanged is how many packages have the same version in both
+	// channels.
+	Unchanged int
+}
+
+// Count returns how many of the changes are of the given kind.
+func (d ChannelDiff) Count(kind ChangeKind) int {
+	var n int
+	for _, change := range d.Changes {
+		if change.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// DiffChannels compares the packages that match the query in two channels,
+// fetching every one of them with [Client.SearchAll]. The channel and page
+// size of the query are ignored; an empty query compares every package.
+// Versions are compared the same way as Nix does (see [nixversion.Compare]).
+func DiffChannels(ctx context.Context, client Client, query Query, from, to string) (ChannelDiff, error) {
+	oldVersions, err := allVersions(ctx, client, query, from)
+	if err != nil {
+		return ChannelDiff{}, err
+	}
+	newVersions, err := allVersions(ctx, client, query, to)
+	if err != nil {
+		return ChannelDiff{}, err
+	}
+
+	diff := ChannelDiff{From: from, To: to}
+	attrNames := slices.Concat(slices.Collect(maps.Keys(oldVersions)), slices.Collect(maps.Keys(newVersions)))
+	slices.Sort(attrNames)
+	for _, attrName := range slices.Compact(attrNames) {
+		oldVersion, inOld := oldVersions[attrName]
+		newVersion, inNew := newVersions[attrName]
+		change := PackageChange{AttrName: attrName, From: oldVersion, To: newVersion}
+		switch {
+		case !inOld:
+			change.Kind = Added
+		case !inNew:
+			change.Kind = Removed
+		default:
+			switch nixversion.Compare(oldVersion, newVersion) {
+			case -1:
+				change.Kind = Upgraded
+			case 1:
+				change.Kind = Downgraded
+			default:
+				diff.Unchanged++
+				continue
+			}
+			change.MajorBump = isMajorBump(oldVersion, newVersion)
+		}
+		diff.Changes = append(diff.Changes, change)
+	}
+	return diff, nil
+}
+
+// allVersions returns the newest version of every package in the channel
+// that matches the query.
+func allVersions(ctx context.Context, client Client, query Query, channel string) (map[string]string, error) {
+	query.Channel = channel
+	query.Flakes = false
+	query.MaxResults = diffPageSize
+	query.From = 0
+	query.SearchAfter = nil
+	versions := map[string]string{}
+	for pkg, err := range client.SearchAll(ctx, query) {
+		if err != nil {
+			return nil, err
+		}
+		addNewestVersion(versions, pkg)
+	}
+	return versions, nil
+}
+
+// isMajorBump reports whether the first component of a version changed,
+// like "1.9.2" to "2.0.0". Both versions must start with a number, and
+// neither can look like a date, since a new year isn't a breaking change.
+func isMajorBump(oldVersion, newVersion string) bool {
+	oldMajor, ok := majorVersion(oldVersion)
+	if !ok {
+		return false
+	}
+	newMajor, ok := majorVersion(newVersion)
+	if !ok {
+		return false
+	}
+	return oldMajor != newMajor
+}
+
+// majorVersion returns the first component of a version, like 1 for
+// "1.9.2". It returns false if the first component isn't a number, or if
+// the version looks like a date, like "2023-11-01", "20231101", or
+// "0-unstable-2024-01-01".
+func majorVersion(version string) (int, bool) {
+	components := nixversion.Split(version)
+	if len(components) == 0 || slices.Contains(components, "unstable") {
+		return 0, false
+	}
+	// A year, or a whole date, is four or more digits.
+	if len(components[0]) >= 4 {
+		return 0, false
+	}
+	major, err := strconv.Atoi(components[0])
+	if err != nil {
+		return 0, false
+	}
+	return major, true
+}
@@ -0,0 +1,80 @@
+package nixsearch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+func TestDiffChannels(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	client, err := NewLocalClient(t.TempDir())
+	assert.NoError(t, err)
+	_, err = client.Mirror.Write("nixos-23.11", packageSeq(
+		Package{Type: "package", AttrName: "go", Version: "1.21.9"},
+		Package{Type: "package", AttrName: "nodejs", Version: "18.19.1"},
+		Package{Type: "package", AttrName: "python2", Version: "2.7.18"},
+		Package{Type: "package", AttrName: "ripgrep", Version: "14.1.0"},
+		Package{Type: "package", AttrName: "terraform", Version: "1.6.6"},
+	))
+	assert.NoError(t, err)
+	_, err = client.Mirror.Write("nixos-24.05", packageSeq(
+		Package{Type: "package", AttrName: "go", Version: "1.22.3"},
+		Package{Type: "package", AttrName: "nodejs", Version: "20.12.2"},
+		Package{Type: "package", AttrName: "opentofu", Version: "1.7.2"},
+		Package{Type: "package", AttrName: "ripgrep", Version: "14.1.0"},
+		Package{Type: "package", AttrName: "terraform", Version: "1.6.6pre1"},
+	))
+	assert.NoError(t, err)
+
+	diff, err := DiffChannels(ctx, client, Query{}, "23.11", "24.05")
+	assert.NoError(t, err)
+	check.Equal(t, []PackageChange{
+		{AttrName: "go", Kind: Upgraded, From: "1.21.9", To: "1.22.3"},
+		{AttrName: "nodejs", Kind: Upgraded, From: "18.19.1", To: "20.12.2", MajorBump: true},
+		{AttrName: "opentofu", Kind: Added, To: "1.7.2"},
+		{AttrName: "python2", Kind: Removed, From: "2.7.18"},
+		{AttrName: "terraform", Kind: Downgraded, From: "1.6.6", To: "1.6.6pre1"},
+	}, diff.Changes)
+	check.Equal(t, 1, diff.Unchanged)
+	check.Equal(t, 2, diff.Count(Upgraded))
+
+	// The query narrows down which packages are compared.
+	diff, err = DiffChannels(ctx, client, Query{Name: &MatchName{Name: "go"}}, "23.11", "24.05")
+	assert.NoError(t, err)
+	check.Equal(t, []PackageChange{
+		{AttrName: "go", Kind: Upgraded, From: "1.21.9", To: "1.22.3"},
+	}, diff.Changes)
+}
+
+func TestIsMajorBump(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		from, to string
+		want     bool
+	}{
+		{"1.9.2", "2.0.0", true},
+		{"18.19.1", "20.12.2", true},
+		{"0.9", "1.0", true},
+		{"1.21.9", "1.22.3", false},
+		{"01.2", "1.3", false},
+		// Versions that don't start with a number.
+		{"v1.2", "v2.0", false},
+		{"r1234", "r1300", false},
+		{"1.2", "git", false},
+		// Dates aren't major versions.
+		{"2023-11-01", "2024-05-01", false},
+		{"20231101", "20240501", false},
+		{"unstable-2023-11-01", "unstable-2024-05-01", false},
+		{"0-unstable-2023-11-01", "1.0", false},
+	} {
+		t.Run(tc.from+" to "+tc.to, func(t *testing.T) {
+			t.Parallel()
+			check.Equal(t, tc.want, isMajorBump(tc.from, tc.to))
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package nixsearch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// These errors describe why a search failed, so that callers can tell the
+// user what to do about it. Check for them with [errors.Is]; the errors
+// returned by clients wrap them with more details.
+var (
+	// ErrChannelNotFound means that the channel, or the index for it,
+	// doesn't exist.
+	ErrChannelNotFound = errors.New("channel not found")
+	// ErrUnauthorized means that the cluster rejected the username and
+	// password.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrRateLimited means that the cluster is refusing requests because
+	// too many have been sent.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrUpstreamUnavailable means that the cluster couldn't be reached, or
+	// that something in front of it, like a proxy, failed.
+	ErrUpstreamUnavailable = errors.New("search backend unavailable")
+	// ErrBadQueryString means that a [MatchQueryString] query couldn't be
+	// parsed. The error is a [QueryStringError] or a [ParseError], with the
+	// position of the problem.
+	ErrBadQueryString = errors.New("invalid query string")
+)
+
+// APIError is returned when the cluster responds to a request with an
+// error. If the cause is known, it matches one of the sentinel errors, like
+// [ErrRateLimited], with [errors.Is].
+type APIError struct {
+	StatusCode int
+	// Err is the error that ElasticSearch reported, or nil if the response
+	// wasn't an ElasticSearch error, like an HTML page from a proxy.
+	Err *Error
+	// Body is the start of the response, when Err is nil.
+	Body string
+	// cause is one of the sentinel errors, or a [QueryStringError].
+	cause error
+}
+
+func (e APIError) Error() string {
+	var msg string
+	if e.Err != nil {
+		msg = e.Err.Error()
+	} else {
+		msg = fmt.Sprintf("unexpected response: HTTP %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+		if e.Body != "" {
+			msg += ": " + e.Body
+		}
+	}
+	if e.cause != nil {
+		msg = e.cause.Error() + ": " + msg
+	}
+	return msg
+}
+
+func (e APIError) Unwrap() []error {
+	var errs []error
+	if e.cause != nil {
+		errs = append(errs, e.cause)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// QueryStringError is returned when ElasticSearch couldn't parse a query
+// string. It matches [ErrBadQueryString].
+type QueryStringError struct {
+	// Query is the query string that ElasticSearch was sent, after any
+	// aliases were rewritten, if it's known.
+	Query string
+	// Pos is the byte offset into the query string where the problem is,
+	// or -1 if it isn't known.
+	Pos    int
+	Reason string
+}
+
+func (e QueryStringError) Error() string {
+	if e.Pos < 0 {
+		return fmt.Sprintf("%s: %s", ErrBadQueryString, e.Reason)
+	}
+	return fmt.Sprintf("%s at column %d: %s", ErrBadQueryString, e.Pos+1, e.Reason)
+}
+
+func (e QueryStringError) Is(target error) bool {
+	return target == ErrBadQueryString
+}
+
+// maxErrorBody is how much of a response that isn't an ElasticSearch error
+// is kept in an [APIError].
+const maxErrorBody = 200
+
+// newAPIError classifies a failed response by its status code and the type
+// of the ElasticSearch error in its body, if it has one.
+func newAPIError(statusCode int, body []byte) APIError {
+	e := APIError{StatusCode: statusCode}
+	var r Response
+	if err := json.Unmarshal(body, &r); err == nil && r.Error != nil {
+		e.Err = r.Error
+	} else {
+		e.Body = truncate(strings.TrimSpace(string(body)), maxErrorBody)
+	}
+
+	types := map[string]*Error{}
+	if e.Err != nil {
+		for _, cause := range e.Err.causes() {
+			types[cause.Type] = cause
+		}
+	}
+	switch {
+	case types["index_not_found_exception"] != nil:
+		e.cause = ErrChannelNotFound
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden || types["security_exception"] != nil:
+		e.cause = ErrUnauthorized
+	case statusCode == http.StatusTooManyRequests || types["es_rejected_execution_exception"] != nil:
+		e.cause = ErrRateLimited
+	case types["query_shard_exception"] != nil || types["parse_exception"] != nil:
+		e.cause = newQueryStringError(e.Err)
+	case statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout:
+		e.cause = ErrUpstreamUnavailable
+	case statusCode >= 500 && e.Err == nil:
+		// Anything that isn't ElasticSearch's own error came from something
+		// in front of it.
+		e.cause = ErrUpstreamUnavailable
+	}
+	return e
+}
+
+// queryStringColumn finds the column in ElasticSearch's description of a
+// query string that it couldn't parse, like `Encountered " ")" ") "" at line
+// 1, column 9.`
+var queryStringColumn = regexp.MustCompile(`at line 1, column (\d+)`) //nolint:gochecknoglobals
+
+// queryStringQuery finds the query string in ElasticSearch's description of
+// it, like `Failed to parse query [program:(rg]`.
+var queryStringQuery = regexp.MustCompile(`^Failed to parse query \[(.*)\]$`) //nolint:gochecknoglobals
+
+func newQueryStringError(err *Error) QueryStringError {
+	e := QueryStringError{Pos: -1}
+	for _, cause := range err.causes() {
+		// The most specific reason is the one nested the deepest.
+		if cause.Reason != "" {
+			e.Reason = cause.Reason
+		}
+		if m := queryStringQuery.FindStringSubmatch(cause.Reason); m != nil {
+			e.Query = m[1]
+		}
+		if m := queryStringColumn.FindStringSubmatch(cause.Reason); m != nil {
+			column, _ := strconv.Atoi(m[1])
+			e.Pos = column - 1
+		}
+	}
+	e.Reason, _, _ = strings.Cut(e.Reason, "\n")
+	return e
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
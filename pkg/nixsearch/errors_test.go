@@ -0,0 +1,131 @@
+package nixsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+func TestAPIErrorClassification(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"unknown index", 404, `{"error":{"type":"index_not_found_exception","reason":"no such index [latest-43-nixos-99.99]","resource.type":"index_or_alias","resource.id":"latest-43-nixos-99.99"},"status":404}`, ErrChannelNotFound},
+		{"bad credentials", 401, `{"error":{"type":"security_exception","reason":"unable to authenticate user"},"status":401}`, ErrUnauthorized},
+		{"forbidden", 403, `Forbidden`, ErrUnauthorized},
+		{"too many requests", 429, `{"error":{"type":"es_rejected_execution_exception","reason":"rejected execution"},"status":429}`, ErrRateLimited},
+		{"proxy error", 502, `<html><body><h1>502 Bad Gateway</h1></body></html>`, ErrUpstreamUnavailable},
+		{"overloaded", 503, `{"error":{"type":"cluster_block_exception","reason":"blocked"},"status":503}`, ErrUpstreamUnavailable},
+		{"html error", 500, `<html>oops</html>`, ErrUpstreamUnavailable},
+		{"bad query string", 400, queryStringFailure, ErrBadQueryString},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := error(newAPIError(tc.status, []byte(tc.body)))
+			check.True(t, errors.Is(err, tc.want))
+			var apiErr APIError
+			check.True(t, errors.As(err, &apiErr))
+			check.Equal(t, tc.status, apiErr.StatusCode)
+		})
+	}
+
+	// Errors that aren't understood don't match any of the sentinels.
+	err := error(newAPIError(400, []byte(`{"error":{"type":"illegal_argument_exception","reason":"nope"},"status":400}`)))
+	for _, sentinel := range []error{ErrChannelNotFound, ErrUnauthorized, ErrRateLimited, ErrUpstreamUnavailable, ErrBadQueryString} {
+		check.False(t, errors.Is(err, sentinel))
+	}
+	var esErr *Error
+	check.True(t, errors.As(err, &esErr))
+	check.Equal(t, "nope", esErr.Reason)
+}
+
+// queryStringFailure is how ElasticSearch reports a query string that it
+// can't parse.
+const queryStringFailure = `{
+	"error": {
+		"root_cause": [{"type": "query_shard_exception", "reason": "Failed to parse query [package_programs:(rg]"}],
+		"type": "search_phase_execution_exception",
+		"reason": "all shards failed",
+		"failed_shards": [{
+			"shard": 0,
+			"reason": {
+				"type": "query_shard_exception",
+				"reason": "Failed to parse query [package_programs:(rg]",
+				"caused_by": {
+					"type": "parse_exception",
+					"reason": "Cannot parse 'package_programs:(rg': Encountered \"<EOF>\" at line 1, column 20.\nWas expecting one of: ...",
+					"caused_by": {
+						"type": "parse_exception",
+						"reason": "Encountered \"<EOF>\" at line 1, column 20.\nWas expecting one of: ..."
+					}
+				}
+			}
+		}]
+	},
+	"status": 400
+}`
+
+func TestQueryStringError(t *testing.T) {
+	t.Parallel()
+
+	err := error(newAPIError(400, []byte(queryStringFailure)))
+	var qsErr QueryStringError
+	assert.True(t, errors.As(err, &qsErr))
+	check.Equal(t, "package_programs:(rg", qsErr.Query)
+	check.Equal(t, 19, qsErr.Pos)
+	check.Equal(t, `Encountered "<EOF>" at line 1, column 20.`, qsErr.Reason)
+
+	// Query strings that are caught before they're sent match too.
+	_, err = Query{QueryString: &MatchQueryString{QueryString: "program:(rg"}}.Payload()
+	check.True(t, errors.Is(err, ErrBadQueryString))
+	var parseErr ParseError
+	check.True(t, errors.As(err, &parseErr))
+}
+
+func TestClientErrors(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	respond := func(status int, body string) *ElasticSearchClient {
+		client, err := NewElasticSearchClientWithOptions(ClientOptions{
+			Transport: roundTripFunc(func(_ *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+			}),
+		})
+		assert.NoError(t, err)
+		return client
+	}
+	query := Query{Channel: "99.99", MaxResults: 1, Name: &MatchName{Name: "x"}}
+
+	_, err := respond(404, `{"error":{"type":"index_not_found_exception","reason":"no such index"},"status":404}`).Search(ctx, query)
+	check.True(t, errors.Is(err, ErrChannelNotFound))
+
+	// A response that isn't JSON is reported as such, rather than as a
+	// decoding error.
+	_, err = respond(200, `<html>captive portal</html>`).Search(ctx, query)
+	check.True(t, errors.Is(err, ErrUpstreamUnavailable))
+	check.True(t, strings.Contains(err.Error(), "captive portal"))
+
+	// But a JSON response that doesn't decode isn't blamed on the server.
+	_, err = respond(200, `{"hits":{"hits":"none"}}`).Search(ctx, query)
+	check.Error(t, err)
+	check.False(t, errors.Is(err, ErrUpstreamUnavailable))
+	var typeErr *json.UnmarshalTypeError
+	check.True(t, errors.As(err, &typeErr))
+
+	// A failed request isn't mistaken for a successful one without results.
+	_, err = respond(400, `{}`).Search(ctx, query)
+	check.Error(t, err)
+}
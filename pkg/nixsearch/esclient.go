@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
 )
@@ -15,9 +19,12 @@ import (
 const (
 	// Taken from the upstream repository
 	// https://github.com/NixOS/nixos-search/blob/main/frontend/src/index.js
-	ElasticSearchUsername    = "aWVSALXpZv"
-	ElasticSearchPassword    = "X8gPHnzL52wFEekuxsfQ9cSh"
-	ElasticSearchURLTemplate = `https://nixos-search-7-1733963800.us-east-1.bonsaisearch.net:443/%s/_search`
+	ElasticSearchUsername = "aWVSALXpZv"
+	ElasticSearchPassword = "X8gPHnzL52wFEekuxsfQ9cSh"
+	ElasticSearchURL      = `https://nixos-search-7-1733963800.us-east-1.bonsaisearch.net:443`
+	// Deprecated: use [ElasticSearchURL], which the index path is appended
+	// to, or [ClientOptions] to search a different cluster.
+	ElasticSearchURLTemplate = ElasticSearchURL + `/%s/_search`
 	// See the list of available indexes at
 	// https://nixos-search-7-1733963800.us-east-1.bonsaisearch.net:443/_aliases
 	// They're in the format "latest-<VERSION>-identifier", e.g.
@@ -31,87 +38,324 @@ const (
 	// for the version number. Experimentally, results are the same as before,
 	// it doesn't matter that we're querying over multiple indices.
 	ElasticSearchIndexPrefix = "latest-*-"
+	// DefaultPageSize is the number of results fetched per request by
+	// SearchAll when the query does not set MaxResults.
+	DefaultPageSize = 100
+	// scrollKeepAlive is how long the cluster should keep a scroll snapshot
+	// open between requests for the next page.
+	scrollKeepAlive = "5m"
 )
 
+// ClientOptions configures which ElasticSearch cluster an
+// [ElasticSearchClient] talks to. Any field left empty falls back to the
+// upstream search.nixos.org defaults, so the zero value is ready to use.
+type ClientOptions struct {
+	// BaseURL is the scheme, host, and port of the cluster, e.g.
+	// "https://search.example.com:443". Defaults to [ElasticSearchURL].
+	BaseURL string
+	// IndexPrefix is prepended to the name of every index that is searched.
+	// Defaults to [ElasticSearchIndexPrefix] when nil; point it at "" to
+	// search indexes that aren't prefixed at all.
+	IndexPrefix *string
+	// Username and Password are sent as HTTP basic auth credentials. When
+	// both are empty, no credentials are sent, except to the upstream
+	// cluster, which gets [ElasticSearchUsername] and [ElasticSearchPassword].
+	Username string
+	Password string
+	// Transport, if set, is used to make the underlying HTTP requests.
+	Transport http.RoundTripper
+}
+
 type ElasticSearchClient struct {
-	HTTPClient *http.Client
+	HTTPClient  *http.Client
+	BaseURL     string
+	IndexPrefix string
+	Username    string
+	Password    string
 }
 
+// NewElasticSearchClient returns a client for the upstream search.nixos.org
+// cluster.
 func NewElasticSearchClient() (*ElasticSearchClient, error) {
+	return NewElasticSearchClientWithOptions(ClientOptions{})
+}
+
+// NewElasticSearchClientWithOptions returns a client for the cluster
+// described by the options.
+func NewElasticSearchClientWithOptions(options ClientOptions) (*ElasticSearchClient, error) {
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryMax = 3
 	retryClient.Logger = nil
+	// Return the last response once the retries run out, rather than a
+	// generic error, so that it can be turned into an [APIError].
+	retryClient.ErrorHandler = retryablehttp.PassthroughErrorHandler
+	if options.Transport != nil {
+		retryClient.HTTPClient.Transport = options.Transport
+	}
 
+	baseURL := firstOf(options.BaseURL, ElasticSearchURL)
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("invalid base url %q: %w", baseURL, err)
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	// The upstream credentials are only for the upstream cluster, they
+	// shouldn't be sent to anyone else's.
+	username, password := options.Username, options.Password
+	if username == "" && password == "" && baseURL == ElasticSearchURL {
+		username, password = ElasticSearchUsername, ElasticSearchPassword
+	}
+	indexPrefix := ElasticSearchIndexPrefix
+	if options.IndexPrefix != nil {
+		indexPrefix = *options.IndexPrefix
+	}
 	return &ElasticSearchClient{
-		HTTPClient: retryClient.StandardClient(),
+		HTTPClient:  retryClient.StandardClient(),
+		BaseURL:     baseURL,
+		IndexPrefix: indexPrefix,
+		Username:    username,
+		Password:    password,
 	}, nil
 }
 
 func (c ElasticSearchClient) Search(ctx context.Context, query Query) ([]Package, error) {
-	req, err := newRequest(ctx, query)
+	result, err := c.SearchWithMeta(ctx, query)
 	if err != nil {
 		return nil, err
 	}
+	return result.Packages(), nil
+}
 
-	resp, err := c.HTTPClient.Do(req)
+func (c ElasticSearchClient) SearchWithMeta(ctx context.Context, query Query) (SearchResult, error) {
+	query, err := c.resolveQuery(ctx, query)
 	if err != nil {
-		return nil, err
+		return SearchResult{}, err
 	}
-
-	packages, err := readResponse(resp)
+	r, err := c.search(ctx, query, true)
 	if err != nil {
-		return nil, err
+		return SearchResult{}, err
 	}
-
-	var out []Package
-	for _, p := range packages {
-		if p.Type != "package" {
+	result := SearchResult{
+		Total:             r.Hits.Total.Value,
+		TotalIsLowerBound: r.Hits.Total.Relation == "gte",
+	}
+	for _, hit := range r.Hits.Hits {
+		if hit.Package.Type != "package" {
 			continue
 		}
-		out = append(out, p)
+		result.Hits = append(result.Hits, PackageHit{
+			Package:    hit.Package,
+			Score:      hit.Score,
+			Index:      hit.Index,
+			Highlights: hit.Highlight,
+		})
+	}
+	return result, nil
+}
+
+// SearchAll pages through every result matching the query, using the sort
+// values of the last hit on each page as the search_after cursor for the next
+// one. Iteration stops after the first error.
+func (c ElasticSearchClient) SearchAll(ctx context.Context, query Query) iter.Seq2[Package, error] {
+	return func(yield func(Package, error) bool) {
+		page, err := c.resolveQuery(ctx, query)
+		if err != nil {
+			yield(Package{}, err)
+			return
+		}
+		if page.MaxResults <= 0 {
+			page.MaxResults = DefaultPageSize
+		}
+		for {
+			// Highlights are only for showing a page of results, so
+			// they're not worth asking for here.
+			r, err := c.search(ctx, page, false)
+			if err != nil {
+				yield(Package{}, err)
+				return
+			}
+			hits := r.Hits.Hits
+			for _, hit := range hits {
+				if hit.Package.Type != "package" {
+					continue
+				}
+				if !yield(hit.Package, nil) {
+					return
+				}
+			}
+			if len(hits) < page.MaxResults {
+				return
+			}
+			page.SearchAfter = hits[len(hits)-1].Sort
+		}
+	}
+}
+
+// Scroll returns every package matching the query using the scroll API, which
+// reads from a consistent snapshot of the index. Unlike SearchAll, results are
+// returned in index order rather than by relevance, which makes this the most
+// efficient way to download an entire channel. query.MaxResults is used as
+// the page size.
+func (c ElasticSearchClient) Scroll(ctx context.Context, query Query) iter.Seq2[Package, error] {
+	return func(yield func(Package, error) bool) {
+		var err error
+		query, err = c.resolveQuery(ctx, query)
+		if err != nil {
+			yield(Package{}, err)
+			return
+		}
+		payload := query.payload()
+		if query.MaxResults <= 0 {
+			payload["size"] = DefaultPageSize
+		}
+		delete(payload, "from")
+		delete(payload, "search_after")
+		delete(payload, "highlight")
+		payload["sort"] = []string{"_doc"}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			yield(Package{}, err)
+			return
+		}
+
+		var scrollID string
+		defer func() {
+			if scrollID != "" {
+				c.clearScroll(scrollID)
+			}
+		}()
+
+		path := c.indexPath(query.Index()) + "/_search?scroll=" + scrollKeepAlive
+		r, err := c.do(ctx, http.MethodPost, path, body)
+		for {
+			if err != nil {
+				yield(Package{}, err)
+				return
+			}
+			scrollID = r.ScrollID
+			if len(r.Hits.Hits) == 0 {
+				return
+			}
+			for _, hit := range r.Hits.Hits {
+				if hit.Package.Type != "package" {
+					continue
+				}
+				if !yield(hit.Package, nil) {
+					return
+				}
+			}
+			body, err = json.Marshal(Dict{"scroll": scrollKeepAlive, "scroll_id": scrollID})
+			if err != nil {
+				yield(Package{}, err)
+				return
+			}
+			r, err = c.do(ctx, http.MethodPost, "/_search/scroll", body)
+		}
 	}
-	return out, nil
 }
 
-func newRequest(ctx context.Context, query Query) (*http.Request, error) {
-	index := ""
+// clearScroll releases the snapshot held open by a scroll. This is a
+// courtesy to the cluster, the snapshot will expire on its own anyway, so any
+// errors are ignored.
+func (c ElasticSearchClient) clearScroll(scrollID string) {
+	body, _ := json.Marshal(Dict{"scroll_id": scrollID})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = c.do(ctx, http.MethodDelete, "/_search/scroll", body)
+}
+
+// resolveQuery returns the query with its channel resolved, if it is one of
+// the symbolic channel names.
+func (c ElasticSearchClient) resolveQuery(ctx context.Context, query Query) (Query, error) {
 	if query.Flakes {
-		index = ElasticSearchIndexPrefix + "group-manual"
-	} else {
-		index = ElasticSearchIndexPrefix + url.QueryEscape("nixos-"+query.Channel)
+		return query, nil
 	}
-	eurl := fmt.Sprintf(ElasticSearchURLTemplate, index)
-	payload, err := query.Payload()
+	channel, err := c.resolveChannel(ctx, query.Channel)
+	query.Channel = channel
+	return query, err
+}
+
+// search fetches a page of results for the query, with highlights of the
+// parts of each result that matched if highlights is set.
+func (c ElasticSearchClient) search(ctx context.Context, query Query, highlights bool) (*Response, error) {
+	payload := query.payload()
+	if !highlights {
+		delete(payload, "highlight")
+	}
+	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, eurl, bytes.NewReader(payload))
+	return c.do(ctx, http.MethodPost, c.indexPath(query.Index())+"/_search", body)
+}
+
+// indexPath returns the URL path of an index, like "nixos-unstable".
+func (c ElasticSearchClient) indexPath(index string) string {
+	return "/" + c.IndexPrefix + url.QueryEscape(index)
+}
+
+// do sends a request with a JSON body to a path on the cluster and decodes the
+// search response.
+func (c ElasticSearchClient) do(ctx context.Context, method, path string, body []byte) (*Response, error) {
+	var r Response
+	if err := c.doInto(ctx, method, path, body, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// doInto sends a request with a JSON body to a path on the cluster and decodes
+// a successful response into out.
+func (c ElasticSearchClient) doInto(ctx context.Context, method, path string, body []byte, out any) error {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	return readResponse(resp, out)
+}
+
+func (c ElasticSearchClient) newRequest(ctx context.Context, method, path string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(ElasticSearchUsername, ElasticSearchPassword)
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 	return req, nil
 }
 
-func readResponse(resp *http.Response) ([]Package, error) {
+func readResponse(resp *http.Response, out any) error {
 	b, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var r Response
-	if err := json.Unmarshal(b, &r); err != nil {
-		return nil, err
-	}
-
 	if resp.StatusCode != http.StatusOK {
-		return nil, r.Error
+		return newAPIError(resp.StatusCode, b)
 	}
-
-	packages := make([]Package, len(r.Hits.Hits))
-	for i, hit := range r.Hits.Hits {
-		packages[i] = hit.Package
+	if err := json.Unmarshal(b, out); err != nil {
+		// Proxies can answer with a page that isn't JSON, even with a 200.
+		// JSON that doesn't decode is a different problem, so it's reported
+		// as it is.
+		if json.Valid(b) {
+			return fmt.Errorf("could not decode response: %w", err)
+		}
+		return APIError{
+			StatusCode: resp.StatusCode,
+			Body:       truncate(strings.TrimSpace(string(b)), maxErrorBody),
+			cause:      ErrUpstreamUnavailable,
+		}
 	}
-	return packages, nil
+	return nil
 }
@@ -0,0 +1,205 @@
+package nixsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+// roundTripFunc lets tests stand in for the ElasticSearch API.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func jsonResponse(t *testing.T, status int, body any) *http.Response {
+	t.Helper()
+	b, err := json.Marshal(body)
+	assert.NoError(t, err)
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(b))),
+	}
+}
+
+func TestSearchAllFollowsCursor(t *testing.T) {
+	t.Parallel()
+
+	pages := [][]Hit{
+		{
+			{Package: Package{Type: "package", AttrName: "c"}, Sort: []any{3.0, "c", "1"}},
+			{Package: Package{Type: "package", AttrName: "b"}, Sort: []any{2.0, "b", "1"}},
+		},
+		{
+			{Package: Package{Type: "package", AttrName: "a"}, Sort: []any{1.0, "a", "1"}},
+		},
+	}
+	var cursors []any
+	client, err := NewElasticSearchClientWithOptions(ClientOptions{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			var payload Dict
+			assert.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			cursors = append(cursors, payload["search_after"])
+			// Highlights aren't needed when walking every result.
+			_, ok := payload["highlight"]
+			check.False(t, ok)
+			var r Response
+			r.Hits.Hits = pages[len(cursors)-1]
+			return jsonResponse(t, http.StatusOK, r), nil
+		}),
+	})
+	assert.NoError(t, err)
+
+	var names []string
+	query := Query{MaxResults: 2, Name: &MatchName{Name: "x"}}
+	for pkg, err := range client.SearchAll(context.Background(), query) {
+		assert.NoError(t, err)
+		names = append(names, pkg.AttrName)
+	}
+	check.Equal(t, []string{"c", "b", "a"}, names)
+	// The second request continues from the sort values of the last hit on
+	// the first page, and the short second page ends the iteration.
+	check.Equal(t, []any{nil, []any{2.0, "b", "1"}}, cursors)
+}
+
+func TestSearchWithMeta(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewElasticSearchClientWithOptions(ClientOptions{
+		Transport: roundTripFunc(func(_ *http.Request) (*http.Response, error) {
+			return jsonResponse(t, http.StatusOK, Dict{
+				"hits": Dict{
+					"total": Dict{"value": 1432, "relation": "eq"},
+					"hits": []Dict{
+						{
+							"_index":    "nixos-43-unstable-abc",
+							"_score":    12.5,
+							"_source":   Dict{"type": "package", "package_attr_name": "ripgrep"},
+							"highlight": Dict{"package_programs": []string{"<em>rg</em>"}},
+						},
+					},
+				},
+			}), nil
+		}),
+	})
+	assert.NoError(t, err)
+
+	result, err := client.SearchWithMeta(context.Background(), Query{MaxResults: 1, Program: &MatchProgram{Program: "rg"}})
+	assert.NoError(t, err)
+	check.Equal(t, 1432, result.Total)
+	check.False(t, result.TotalIsLowerBound)
+	check.Equal(t, []PackageHit{{
+		Package:    Package{Type: "package", AttrName: "ripgrep"},
+		Score:      12.5,
+		Index:      "nixos-43-unstable-abc",
+		Highlights: map[string][]string{"package_programs": {"<em>rg</em>"}},
+	}}, result.Hits)
+
+	// Older versions of ElasticSearch report the total as a plain number.
+	var r Response
+	assert.NoError(t, json.Unmarshal([]byte(`{"hits": {"total": 7, "hits": []}}`), &r))
+	check.Equal(t, Total{Value: 7, Relation: "eq"}, r.Hits.Total)
+}
+
+func TestClientOptions(t *testing.T) {
+	t.Parallel()
+
+	var requests []*http.Request
+	client, err := NewElasticSearchClientWithOptions(ClientOptions{
+		BaseURL:     "https://mirror.example.com:9200/",
+		IndexPrefix: ptr("nixos-search-"),
+		Username:    "user",
+		Password:    "hunter2",
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req)
+			return jsonResponse(t, http.StatusOK, Response{}), nil
+		}),
+	})
+	assert.NoError(t, err)
+	_, err = client.Search(context.Background(), Query{Channel: "24.05", MaxResults: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(requests))
+	check.Equal(t, "https://mirror.example.com:9200/nixos-search-nixos-24.05/_search", requests[0].URL.String())
+	username, password, ok := requests[0].BasicAuth()
+	check.True(t, ok)
+	check.Equal(t, "user", username)
+	check.Equal(t, "hunter2", password)
+
+	// The zero value points at the upstream cluster.
+	client, err = NewElasticSearchClient()
+	assert.NoError(t, err)
+	check.Equal(t, ElasticSearchURL, client.BaseURL)
+	check.Equal(t, ElasticSearchIndexPrefix, client.IndexPrefix)
+	check.Equal(t, ElasticSearchUsername, client.Username)
+	check.Equal(t, ElasticSearchPassword, client.Password)
+
+	// The upstream credentials aren't sent to other clusters, and the
+	// prefix can be turned off.
+	requests = nil
+	client, err = NewElasticSearchClientWithOptions(ClientOptions{
+		BaseURL:     "https://mirror.example.com:9200",
+		IndexPrefix: ptr(""),
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req)
+			return jsonResponse(t, http.StatusOK, Response{}), nil
+		}),
+	})
+	assert.NoError(t, err)
+	_, err = client.Search(context.Background(), Query{Channel: "24.05", MaxResults: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(requests))
+	check.Equal(t, "https://mirror.example.com:9200/nixos-24.05/_search", requests[0].URL.String())
+	_, _, ok = requests[0].BasicAuth()
+	check.False(t, ok)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestScroll(t *testing.T) {
+	t.Parallel()
+
+	var requests []string
+	client, err := NewElasticSearchClientWithOptions(ClientOptions{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req.Method+" "+req.URL.Path)
+			var r Response
+			r.ScrollID = "scroll-1"
+			switch len(requests) {
+			case 1:
+				r.Hits.Hits = []Hit{
+					{Package: Package{Type: "package", AttrName: "a"}},
+					{Package: Package{Type: "option", AttrName: "not-a-package"}},
+				}
+			case 2:
+				r.Hits.Hits = []Hit{{Package: Package{Type: "package", AttrName: "b"}}}
+			}
+			return jsonResponse(t, http.StatusOK, r), nil
+		}),
+	})
+	assert.NoError(t, err)
+
+	var names []string
+	for pkg, err := range client.Scroll(context.Background(), Query{Channel: "unstable", MaxResults: 2}) {
+		assert.NoError(t, err)
+		names = append(names, pkg.AttrName)
+	}
+	check.Equal(t, []string{"a", "b"}, names)
+	// Pages are read until one comes back empty, and then the scroll is
+	// cleared.
+	check.Equal(t, []string{
+		"POST /latest-*-nixos-unstable/_search",
+		"POST /_search/scroll",
+		"POST /_search/scroll",
+		"DELETE /_search/scroll",
+	}, requests)
+}
@@ -1,6 +1,9 @@
 package nixsearch
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 // Response is the format for an ElasticSearch API response.
 // If the request was successful, only `Hits` will be populated.
@@ -8,25 +11,107 @@ import "fmt"
 type Response struct {
 	Error  *Error `json:"error"`
 	Status *int   `json:"status"`
-	Hits   struct {
-		Hits []Hit `json:"hits"`
+	// ScrollID is set when the request opened or continued a scroll, and
+	// identifies the snapshot to read the next page of results from.
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Total Total `json:"total"`
+		Hits  []Hit `json:"hits"`
 	} `json:"hits"`
 }
 
+// Total is the number of documents that matched a search. Relation is "eq"
+// if the count is exact, or "gte" if ElasticSearch stopped counting early.
+type Total struct {
+	Value    int    `json:"value"`
+	Relation string `json:"relation"`
+}
+
+// UnmarshalJSON also accepts a plain number, which is how versions of
+// ElasticSearch before 7.0 report the total.
+func (t *Total) UnmarshalJSON(b []byte) error {
+	type total Total // avoid infinite recursion
+	if err := json.Unmarshal(b, &t.Value); err == nil {
+		t.Relation = "eq"
+		return nil
+	}
+	return json.Unmarshal(b, (*total)(t))
+}
+
 type Error struct {
 	Type         string `json:"type"`
 	Reason       string `json:"reason"`
 	ResourceType string `json:"resource.type"`
 	ResourceID   string `json:"resource.id"`
+	// RootCause, FailedShards, and CausedBy are the errors that caused this
+	// one, which are often more specific.
+	RootCause    []Error `json:"root_cause"`
+	FailedShards []struct {
+		Reason Error `json:"reason"`
+	} `json:"failed_shards"`
+	CausedBy *Error `json:"caused_by"`
 }
 
 func (e Error) Error() string {
 	return fmt.Sprintf("API failure[%s](%s=%s): %s", e.Type, e.ResourceType, e.ResourceID, e.Reason)
 }
 
+// causes returns the error and every error nested inside of it, with the
+// most deeply nested last.
+func (e *Error) causes() []*Error {
+	out := []*Error{e}
+	for i := range e.RootCause {
+		out = append(out, e.RootCause[i].causes()...)
+	}
+	for i := range e.FailedShards {
+		out = append(out, e.FailedShards[i].Reason.causes()...)
+	}
+	if e.CausedBy != nil {
+		out = append(out, e.CausedBy.causes()...)
+	}
+	return out
+}
+
 type Hit struct {
 	ID      string  `json:"_id"`
+	Index   string  `json:"_index"`
+	Score   float64 `json:"_score"`
 	Package Package `json:"_source"`
+	// Option is set instead of Package when the hit is a NixOS option.
+	Option Option `json:"-"`
+	// Sort holds the values this hit was sorted by, which can be passed as
+	// [Query.SearchAfter] to fetch the page of results following this hit.
+	Sort []any `json:"sort"`
+	// Highlight holds fragments of the fields that matched the query, if
+	// they were requested.
+	Highlight map[string][]string `json:"highlight"`
+}
+
+// UnmarshalJSON decodes the document in the hit into either Package or Option,
+// depending on its type.
+func (h *Hit) UnmarshalJSON(b []byte) error {
+	type hit Hit // avoid infinite recursion
+	var raw struct {
+		hit
+		Source json.RawMessage `json:"_source"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*h = Hit(raw.hit)
+	if len(raw.Source) == 0 {
+		return nil
+	}
+	var doc struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw.Source, &doc); err != nil {
+		return err
+	}
+	if doc.Type == "option" {
+		return json.Unmarshal(raw.Source, &h.Option)
+	}
+	return json.Unmarshal(raw.Source, &h.Package)
 }
 
 type License struct {
@@ -34,6 +119,40 @@ type License struct {
 	URL      string `json:"url"`
 }
 
+// Maintainer is a nixpkgs maintainer. Any of the fields may be empty.
+type Maintainer struct {
+	Name   string `json:"name"`
+	GitHub string `json:"github"`
+	Email  string `json:"email"`
+}
+
+// Team is a group of nixpkgs maintainers who share responsibility for
+// packages, like "gnome" or "rust".
+type Team struct {
+	ShortName   string            `json:"shortName"`
+	Scope       OneOrMany[string] `json:"scope"`
+	Members     []Maintainer      `json:"members"`
+	GitHubTeams OneOrMany[string] `json:"githubTeams"`
+}
+
+// OneOrMany decodes a JSON value that may be a single value, a list of
+// values, or null, which the index uses interchangeably for some fields.
+type OneOrMany[T any] []T
+
+func (o *OneOrMany[T]) UnmarshalJSON(b []byte) error {
+	var many []T
+	if err := json.Unmarshal(b, &many); err == nil {
+		*o = many
+		return nil
+	}
+	var one T
+	if err := json.Unmarshal(b, &one); err != nil {
+		return err
+	}
+	*o = OneOrMany[T]{one}
+	return nil
+}
+
 type FlakeResolved struct {
 	Type  string `json:"type"`
 	Owner string `json:"owner"`
@@ -53,6 +172,9 @@ type Package struct {
 	Platforms        []string      `json:"package_platforms"`
 	Position         string        `json:"package_position"`
 	Licenses         []License     `json:"package_license"`
+	Maintainers      []Maintainer  `json:"package_maintainers"`
+	MaintainersSet   []string      `json:"package_maintainers_set"`
+	Teams            []Team        `json:"package_teams"`
 	FlakeName        string        `json:"flake_name"`
 	FlakeDescription string        `json:"flake_description"`
 	FlakeResolved    FlakeResolved `json:"flake_resolved"`
@@ -86,3 +208,26 @@ func (p Package) ID() string {
 	}
 	return p.AttrName
 }
+
+// Option is a NixOS configuration option, like `services.nginx.enable`.
+// The Default and Example values are Nix expressions, rendered as text.
+type Option struct {
+	Type             string        `json:"type"`
+	Name             string        `json:"option_name"`
+	Description      string        `json:"option_description"`
+	OptionType       string        `json:"option_type"`
+	Default          string        `json:"option_default"`
+	Example          string        `json:"option_example"`
+	Source           string        `json:"option_source"`
+	Flake            []string      `json:"option_flake"`
+	FlakeName        string        `json:"flake_name"`
+	FlakeDescription string        `json:"flake_description"`
+	FlakeResolved    FlakeResolved `json:"flake_resolved"`
+	// Stale is set by a [CachingClient] when the option comes from an
+	// expired cache entry, returned because the search itself failed.
+	Stale bool `json:"stale,omitempty"`
+}
+
+func (o Option) IsFlake() bool {
+	return o.FlakeResolved.Type != ""
+}
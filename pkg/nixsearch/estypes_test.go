@@ -0,0 +1,31 @@
+package nixsearch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+func TestDecodeMaintainersAndTeams(t *testing.T) {
+	t.Parallel()
+
+	var pkg Package
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"package_maintainers": [{"name": "Tom Hall", "github": "tomhall", "email": null}],
+		"package_maintainers_set": ["Tom Hall"],
+		"package_teams": [
+			{"shortName": "Rust", "scope": "Maintain the Rust compiler toolchain and nixpkgs integration.", "githubTeams": ["rust"], "members": [{"name": "Ada", "github": "ada"}]},
+			{"shortName": "Go", "scope": ["Maintain Go compilers."], "githubTeams": null}
+		]
+	}`), &pkg))
+	check.Equal(t, []Maintainer{{Name: "Tom Hall", GitHub: "tomhall"}}, pkg.Maintainers)
+	check.Equal(t, []string{"Tom Hall"}, pkg.MaintainersSet)
+	assert.Equal(t, 2, len(pkg.Teams))
+	check.Equal(t, OneOrMany[string]{"Maintain the Rust compiler toolchain and nixpkgs integration."}, pkg.Teams[0].Scope)
+	check.Equal(t, OneOrMany[string]{"rust"}, pkg.Teams[0].GitHubTeams)
+	check.Equal(t, []Maintainer{{Name: "Ada", GitHub: "ada"}}, pkg.Teams[0].Members)
+	check.Equal(t, OneOrMany[string]{"Maintain Go compilers."}, pkg.Teams[1].Scope)
+	check.Equal(t, 0, len(pkg.Teams[1].GitHubTeams))
+}
@@ -0,0 +1,95 @@
+package nixsearch
+
+import (
+	"strings"
+)
+
+// Highlights wrap the parts of a field that matched the query in these tags.
+const (
+	highlightPreTag  = "<em>"
+	highlightPostTag = "</em>"
+)
+
+// highlightFields are the fields that ElasticSearch is asked to return
+// highlights for; they're the fields that are shown in search results.
+var highlightFields = []string{ //nolint:gochecknoglobals
+	"package_attr_name",
+	"package_pname",
+	"package_programs",
+	"package_description",
+	"package_pversion",
+}
+
+// highlight returns the "highlight" section of a search payload. Each field
+// is returned whole, rather than as fragments, so that a highlight can be
+// matched up with the value of the field that it highlights.
+func highlight() Dict {
+	fields := Dict{}
+	for _, field := range highlightFields {
+		fields[field] = Dict{}
+	}
+	return Dict{
+		"pre_tags":            []string{highlightPreTag},
+		"post_tags":           []string{highlightPostTag},
+		"number_of_fragments": 0,
+		"require_field_match": false,
+		"fields":              fields,
+	}
+}
+
+// Span is part of the value of a field, which either did or didn't match the
+// query.
+type Span struct {
+	Text    string
+	Matched bool
+}
+
+// ParseHighlight splits a highlighted value, like "<em>rip</em>grep", into
+// the spans that did and didn't match.
+func ParseHighlight(highlighted string) []Span {
+	var spans []Span
+	for highlighted != "" {
+		before, rest, found := strings.Cut(highlighted, highlightPreTag)
+		if before != "" {
+			spans = append(spans, Span{Text: before})
+		}
+		if !found {
+			break
+		}
+		matched, after, _ := strings.Cut(rest, highlightPostTag)
+		if matched != "" {
+			spans = append(spans, Span{Text: matched, Matched: true})
+		}
+		highlighted = after
+	}
+	return spans
+}
+
+// Highlight returns the spans of value, one of the values of the given field,
+// that matched the query. If the field wasn't highlighted, or value wasn't
+// one of the highlighted values, the whole value is returned as a single
+// unmatched span.
+func (h PackageHit) Highlight(field, value string) []Span {
+	for _, highlighted := range h.Highlights[field] {
+		spans := ParseHighlight(highlighted)
+		var plain strings.Builder
+		for _, span := range spans {
+			plain.WriteString(span.Text)
+		}
+		if plain.String() == value {
+			return spans
+		}
+	}
+	return []Span{{Text: value}}
+}
+
+// IsHighlighted reports whether any part of value, one of the values of the
+// given field, matched the query.
+func (h PackageHit) IsHighlighted(field, value string) bool {
+	for _, span := range h.Highlight(field, value) {
+		if span.Matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package nixsearch
+
+import (
+	"testing"
+
+	"github.com/peterldowns/testy/check"
+)
+
+func TestPayloadHighlight(t *testing.T) {
+	t.Parallel()
+
+	// Whole fields are highlighted, rather than fragments of them, so that
+	// each highlight can be matched up with the value it highlights.
+	payload := decodePayload(t, Query{MaxResults: 1, Search: &MatchSearch{Search: "rip"}})
+	highlight, ok := payload["highlight"].(map[string]any)
+	check.True(t, ok)
+	check.Equal[any](t, 0.0, highlight["number_of_fragments"])
+	check.Equal[any](t, []any{"<em>"}, highlight["pre_tags"])
+	check.Equal[any](t, []any{"</em>"}, highlight["post_tags"])
+	fields, ok := highlight["fields"].(map[string]any)
+	check.True(t, ok)
+	for _, field := range []string{"package_attr_name", "package_programs", "package_description"} {
+		_, found := fields[field]
+		check.True(t, found)
+	}
+}
+
+func TestParseHighlight(t *testing.T) {
+	t.Parallel()
+
+	check.Equal(t, nil, ParseHighlight(""))
+	check.Equal(t, []Span{{Text: "ripgrep"}}, ParseHighlight("ripgrep"))
+	check.Equal(t, []Span{
+		{Text: "rip", Matched: true},
+		{Text: "grep-"},
+		{Text: "all", Matched: true},
+	}, ParseHighlight("<em>rip</em>grep-<em>all</em>"))
+}
+
+func TestPackageHitHighlight(t *testing.T) {
+	t.Parallel()
+
+	hit := PackageHit{
+		Package: Package{Programs: []string{"rga", "rga-fzf"}},
+		Highlights: map[string][]string{
+			"package_programs": {"<em>rga</em>-fzf"},
+		},
+	}
+	check.Equal(t, []Span{{Text: "rga", Matched: true}, {Text: "-fzf"}}, hit.Highlight("package_programs", "rga-fzf"))
+	check.True(t, hit.IsHighlighted("package_programs", "rga-fzf"))
+	// Values that weren't highlighted are returned whole.
+	check.Equal(t, []Span{{Text: "rga"}}, hit.Highlight("package_programs", "rga"))
+	check.False(t, hit.IsHighlighted("package_programs", "rga"))
+	check.False(t, hit.IsHighlighted("package_description", ""))
+}
@@ -0,0 +1,140 @@
+package nixsearch
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// InstallRef returns the flake reference that installs the package, like
+// "nixpkgs#ripgrep", for use with commands like "nix profile install".
+//
+// Packages from nixpkgs are installed from the branch of the channel they
+// were found in, like "github:NixOS/nixpkgs/nixos-24.05#ripgrep", except
+// for "unstable" or an empty channel, which use the "nixpkgs" flake from
+// the registry. Flakes are installed from where they were resolved to, like
+// "github:owner/repo#package"; the channel is ignored. It returns an error
+// for flakes that were resolved to somewhere that can't be referred to,
+// which is any type other than the ones above without a URL.
+func (p Package) InstallRef(channel string) (string, error) {
+	if p.IsFlake() {
+		ref, err := p.flakeRef()
+		if err != nil {
+			return "", err
+		}
+		return ref + "#" + p.AttrName, nil
+	}
+	if channel == "" || channel == "unstable" {
+		return "nixpkgs#" + p.AttrName, nil
+	}
+	return fmt.Sprintf("github:NixOS/nixpkgs/nixos-%s#%s", channel, p.AttrName), nil
+}
+
+// flakeRef returns the reference to the flake that the package is from,
+// without the package's attribute.
+func (p Package) flakeRef() (string, error) {
+	resolved := p.FlakeResolved
+	switch resolved.Type {
+	case "github", "gitlab", "sourcehut":
+		return fmt.Sprintf("%s:%s/%s", resolved.Type, resolved.Owner, resolved.Repo), nil
+	case "git":
+		// Flake references to git repositories need the "git+" scheme,
+		// except for git:// URLs.
+		if strings.HasPrefix(resolved.URL, "git+") || strings.HasPrefix(resolved.URL, "git://") {
+			return resolved.URL, nil
+		}
+		return "git+" + resolved.URL, nil
+	default:
+		if resolved.URL != "" {
+			return resolved.URL, nil
+		}
+		return "", fmt.Errorf("can not install %s, its flake was resolved to %q without a URL", p.ID(), resolved.Type)
+	}
+}
+
+// InstallMethod is a way of installing a package, used by
+// [Package.InstallCommand].
+type InstallMethod string
+
+const (
+	// InstallProfile installs the package into the user's profile with
+	// "nix profile install".
+	InstallProfile InstallMethod = "profile"
+	// InstallNixEnv installs the package into the user's profile with
+	// "nix-env", which doesn't support flakes.
+	InstallNixEnv InstallMethod = "nix-env"
+	// InstallShell starts a shell with the package with "nix shell".
+	InstallShell InstallMethod = "shell"
+	// InstallRun runs the package's main program with "nix run".
+	InstallRun InstallMethod = "run"
+	// InstallFlakeRef is just the flake reference, see [Package.InstallRef].
+	InstallFlakeRef InstallMethod = "flake-ref"
+)
+
+// InstallMethods lists every [InstallMethod].
+var InstallMethods = []InstallMethod{ //nolint:gochecknoglobals
+	InstallProfile,
+	InstallNixEnv,
+	InstallShell,
+	InstallRun,
+	InstallFlakeRef,
+}
+
+// ParseInstallMethod returns the [InstallMethod] with the given name.
+func ParseInstallMethod(name string) (InstallMethod, error) {
+	method := InstallMethod(name)
+	if !slices.Contains(InstallMethods, method) {
+		names := make([]string, 0, len(InstallMethods))
+		for _, m := range InstallMethods {
+			names = append(names, string(m))
+		}
+		return "", fmt.Errorf("unknown install method %q, expected one of: %s", name, strings.Join(names, ", "))
+	}
+	return method, nil
+}
+
+// InstallCommand returns the shell command that installs the package, from
+// the channel it was found in, with the given method. It returns an error
+// for flakes with [InstallNixEnv], for flakes that [Package.InstallRef]
+// can't refer to, and for unknown methods.
+func (p Package) InstallCommand(method InstallMethod, channel string) (string, error) {
+	if method == InstallNixEnv {
+		if p.IsFlake() {
+			return "", fmt.Errorf("nix-env can not install flakes like %s", p.ID())
+		}
+		if channel == "" {
+			channel = "unstable"
+		}
+		return fmt.Sprintf("nix-env -f channel:nixos-%s -iA %s", channel, shellQuote(p.AttrName)), nil
+	}
+	ref, err := p.InstallRef(channel)
+	if err != nil {
+		return "", err
+	}
+	ref = shellQuote(ref)
+	switch method {
+	case InstallProfile:
+		return "nix profile install " + ref, nil
+	case InstallShell:
+		return "nix shell " + ref, nil
+	case InstallRun:
+		return "nix run " + ref, nil
+	case InstallFlakeRef:
+		return ref, nil
+	default:
+		return "", fmt.Errorf("unknown install method %q", method)
+	}
+}
+
+// shellSafe matches strings that can be used as a single shell word without
+// quotes.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./#~-]+$`)
+
+// shellQuote quotes s for a POSIX shell, if it needs to be.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,101 @@
+package nixsearch
+
+import (
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+// installRef returns the package's install ref, failing the test if there
+// isn't one.
+func installRef(t *testing.T, pkg Package, channel string) string {
+	t.Helper()
+	ref, err := pkg.InstallRef(channel)
+	assert.NoError(t, err)
+	return ref
+}
+
+func TestInstallRef(t *testing.T) {
+	t.Parallel()
+
+	pkg := Package{AttrName: "ripgrep"}
+	check.Equal(t, "nixpkgs#ripgrep", installRef(t, pkg, ""))
+	check.Equal(t, "nixpkgs#ripgrep", installRef(t, pkg, "unstable"))
+	check.Equal(t, "github:NixOS/nixpkgs/nixos-24.05#ripgrep", installRef(t, pkg, "24.05"))
+
+	// Flakes are installed from where they were resolved to, whichever
+	// channel they were found with.
+	flake := Package{
+		AttrName:      "nix-search",
+		FlakeResolved: FlakeResolved{Type: "github", Owner: "peterldowns", Repo: "nix-search-cli"},
+	}
+	check.Equal(t, "github:peterldowns/nix-search-cli#nix-search", installRef(t, flake, "24.05"))
+	flake.FlakeResolved = FlakeResolved{Type: "gitlab", Owner: "example", Repo: "tools"}
+	check.Equal(t, "gitlab:example/tools#nix-search", installRef(t, flake, ""))
+	flake.FlakeResolved = FlakeResolved{Type: "git", URL: "https://git.example.com/tools"}
+	check.Equal(t, "git+https://git.example.com/tools#nix-search", installRef(t, flake, ""))
+	flake.FlakeResolved = FlakeResolved{Type: "git", URL: "git+ssh://git@example.com/tools"}
+	check.Equal(t, "git+ssh://git@example.com/tools#nix-search", installRef(t, flake, ""))
+	flake.FlakeResolved = FlakeResolved{Type: "git", URL: "git://example.com/tools"}
+	check.Equal(t, "git://example.com/tools#nix-search", installRef(t, flake, ""))
+
+	// Flakes resolved to anywhere else need a URL to be referred to.
+	flake.FlakeResolved = FlakeResolved{Type: "tarball", URL: "https://example.com/tools.tar.gz"}
+	check.Equal(t, "https://example.com/tools.tar.gz#nix-search", installRef(t, flake, ""))
+	flake.FlakeResolved = FlakeResolved{Type: "indirect"}
+	_, err := flake.InstallRef("")
+	check.Error(t, err)
+	_, err = flake.InstallCommand(InstallProfile, "")
+	check.Error(t, err)
+}
+
+func TestInstallCommand(t *testing.T) {
+	t.Parallel()
+
+	pkg := Package{AttrName: "python3Packages.requests"}
+	for _, tc := range []struct {
+		method   InstallMethod
+		channel  string
+		expected string
+	}{
+		{InstallProfile, "unstable", "nix profile install nixpkgs#python3Packages.requests"},
+		{InstallProfile, "24.05", "nix profile install github:NixOS/nixpkgs/nixos-24.05#python3Packages.requests"},
+		{InstallShell, "24.05", "nix shell github:NixOS/nixpkgs/nixos-24.05#python3Packages.requests"},
+		{InstallRun, "unstable", "nix run nixpkgs#python3Packages.requests"},
+		{InstallFlakeRef, "24.05", "github:NixOS/nixpkgs/nixos-24.05#python3Packages.requests"},
+		{InstallNixEnv, "unstable", "nix-env -f channel:nixos-unstable -iA python3Packages.requests"},
+		{InstallNixEnv, "", "nix-env -f channel:nixos-unstable -iA python3Packages.requests"},
+		{InstallNixEnv, "24.05", "nix-env -f channel:nixos-24.05 -iA python3Packages.requests"},
+	} {
+		command, err := pkg.InstallCommand(tc.method, tc.channel)
+		check.Nil(t, err)
+		check.Equal(t, tc.expected, command)
+	}
+
+	// References that aren't safe to use in a shell are quoted.
+	flake := Package{
+		AttrName:      "tool",
+		FlakeResolved: FlakeResolved{Type: "git", URL: "https://example.com/tools?ref=it's"},
+	}
+	command, err := flake.InstallCommand(InstallProfile, "")
+	check.Nil(t, err)
+	check.Equal(t, `nix profile install 'git+https://example.com/tools?ref=it'\''s#tool'`, command)
+
+	_, err = flake.InstallCommand(InstallNixEnv, "")
+	check.Error(t, err)
+	_, err = pkg.InstallCommand("apt-get", "")
+	check.Error(t, err)
+}
+
+func TestParseInstallMethod(t *testing.T) {
+	t.Parallel()
+
+	for _, method := range InstallMethods {
+		parsed, err := ParseInstallMethod(string(method))
+		check.Nil(t, err)
+		check.Equal(t, method, parsed)
+	}
+	_, err := ParseInstallMethod("apt-get")
+	check.Error(t, err)
+}
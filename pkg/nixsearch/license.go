@@ -0,0 +1,187 @@
+package nixsearch
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// UnfreeLicenses are the full names of licenses that nixpkgs marks as unfree
+// in lib/licenses.nix, other than the "Unfree..." licenses, which are matched
+// by prefix instead. The search index doesn't record whether a license is
+// free, so this list is a best-effort copy that can fall behind nixpkgs:
+// append to it to exclude more licenses with [Query.ExcludeUnfree], or
+// exclude them by name with [Query.Not].
+var UnfreeLicenses = []string{ //nolint:gochecknoglobals
+	"Amazon Software License",
+	"Business Source License 1.1",
+	"CUDA Toolkit End User License Agreement (EULA)",
+	"Creative Commons Attribution Non Commercial 3.0 Unported",
+	"Creative Commons Attribution Non Commercial 4.0 International",
+	"Creative Commons Attribution Non Commercial No Derivatives 3.0 Unported",
+	"Creative Commons Attribution Non Commercial No Derivatives 4.0 International",
+	"Creative Commons Attribution Non Commercial Share Alike 2.0 Generic",
+	"Creative Commons Attribution Non Commercial Share Alike 2.5 Generic",
+	"Creative Commons Attribution Non Commercial Share Alike 3.0 Unported",
+	"Creative Commons Attribution Non Commercial Share Alike 4.0 International",
+	"Creative Commons Attribution No Derivatives 4.0 International",
+	"Creative Commons Attribution-No Derivative Works v3.00",
+	"Databricks License",
+	"Elastic License 2.0",
+	"Functional Source License, Version 1.1, ALv2 Future License",
+	"Functional Source License, Version 1.1, MIT Future License",
+	"Hippocratic License v3.0",
+	"Intel Simplified Software License",
+	"OCamlPro Non Commercial license version 1",
+	"Obsidian End User Agreement",
+	"Prosperity-3.0.0",
+	"Server Side Public License",
+	"Sustainable Use License",
+	"Timescale License Agreement",
+}
+
+// unfreeLicensePrefix matches "Unfree", "Unfree redistributable", and
+// "Unfree redistributable firmware".
+const unfreeLicensePrefix = "Unfree"
+
+// spdxLicenses maps the SPDX identifiers of common licenses, in lower case,
+// to their full names, which is how nixpkgs names them. The index only
+// stores full names in a form that can be matched exactly, so SPDX
+// identifiers are translated before searching.
+var spdxLicenses = map[string]string{ //nolint:gochecknoglobals
+	"0bsd":              "BSD Zero Clause License",
+	"agpl-3.0-only":     "GNU Affero General Public License v3.0 only",
+	"agpl-3.0-or-later": "GNU Affero General Public License v3.0 or later",
+	"apache-2.0":        "Apache License 2.0",
+	"artistic-1.0":      "Artistic License 1.0",
+	"artistic-2.0":      "Artistic License 2.0",
+	"bsd-2-clause":      `BSD 2-Clause "Simplified" License`,
+	"bsd-3-clause":      `BSD 3-Clause "New" or "Revised" License`,
+	"bsl-1.0":           "Boost Software License 1.0",
+	"busl-1.1":          "Business Source License 1.1",
+	"cc-by-4.0":         "Creative Commons Attribution 4.0 International",
+	"cc-by-nc-4.0":      "Creative Commons Attribution Non Commercial 4.0 International",
+	"cc-by-sa-4.0":      "Creative Commons Attribution Share Alike 4.0 International",
+	"cc0-1.0":           "Creative Commons Zero v1.0 Universal",
+	"cddl-1.0":          "Common Development and Distribution License 1.0",
+	"elastic-2.0":       "Elastic License 2.0",
+	"epl-1.0":           "Eclipse Public License 1.0",
+	"epl-2.0":           "Eclipse Public License 2.0",
+	"eupl-1.2":          "European Union Public License 1.2",
+	"fsl-1.1-alv2":      "Functional Source License, Version 1.1, ALv2 Future License",
+	"fsl-1.1-mit":       "Functional Source License, Version 1.1, MIT Future License",
+	"gfdl-1.3-or-later": "GNU Free Documentation License v1.3 or later",
+	"gpl-2.0-only":      "GNU General Public License v2.0 only",
+	"gpl-2.0-or-later":  "GNU General Public License v2.0 or later",
+	"gpl-3.0-only":      "GNU General Public License v3.0 only",
+	"gpl-3.0-or-later":  "GNU General Public License v3.0 or later",
+	"hpnd":              "Historical Permission Notice and Disclaimer",
+	"isc":               "ISC License",
+	"lgpl-2.1-only":     "GNU Lesser General Public License v2.1 only",
+	"lgpl-2.1-or-later": "GNU Lesser General Public License v2.1 or later",
+	"lgpl-3.0-only":     "GNU Lesser General Public License v3.0 only",
+	"lgpl-3.0-or-later": "GNU Lesser General Public License v3.0 or later",
+	"mit":               "MIT License",
+	"mpl-1.1":           "Mozilla Public License 1.1",
+	"mpl-2.0":           "Mozilla Public License 2.0",
+	"ms-pl":             "Microsoft Public License",
+	"ncsa":              "University of Illinois/NCSA Open Source License",
+	"ofl-1.1":           "SIL Open Font License 1.1",
+	"openssl":           "OpenSSL License",
+	"postgresql":        "PostgreSQL License",
+	"ruby":              "Ruby License",
+	"sspl-1.0":          "Server Side Public License",
+	"unlicense":         "The Unlicense",
+	"upl-1.0":           "Universal Permissive License v1.0",
+	"vim":               "Vim License",
+	"wtfpl":             "Do What The F*ck You Want To Public License",
+	"x11":               "X11 License",
+	"zlib":              "zlib License",
+}
+
+// SPDXID returns the SPDX identifier of the license, like "MIT", taken from
+// its URL, or "" if it doesn't link to spdx.org.
+func (l License) SPDXID() string {
+	_, id, ok := strings.Cut(l.URL, "spdx.org/licenses/")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSuffix(id, ".html")
+}
+
+// IsUnfree reports whether the license is one that nixpkgs refuses to build
+// without allowUnfree, as far as [UnfreeLicenses] knows.
+func (l License) IsUnfree() bool {
+	return strings.HasPrefix(l.FullName, unfreeLicensePrefix) || slices.Contains(UnfreeLicenses, l.FullName)
+}
+
+// IsUnfree reports whether any of the package's licenses are unfree.
+func (p Package) IsUnfree() bool {
+	return slices.ContainsFunc(p.Licenses, License.IsUnfree)
+}
+
+// MatchLicense filters packages by license, either by SPDX identifier, like
+// "MIT" or "GPL-3.0-or-later", or by full name, or part of it, like "MIT
+// License" or "General Public". An SPDX identifier only matches that exact
+// license, so "MIT" doesn't match "MIT No Attribution" and "GPL-3.0-only"
+// doesn't match "LGPL-3.0-only". Only the SPDX identifiers of common licenses
+// are known; any others have to be given by name.
+type MatchLicense struct {
+	License string
+}
+
+// fullName returns the full name of the license if it is a known SPDX
+// identifier, or "" otherwise.
+func (m MatchLicense) fullName() string {
+	return spdxLicenses[strings.ToLower(m.License)]
+}
+
+func (m MatchLicense) MarshalJSON() ([]byte, error) {
+	// package_license_set is a keyword field with the full name of each of
+	// the package's licenses. package_license has the URLs too, but isn't
+	// indexed in a way that can be searched directly.
+	if name := m.fullName(); name != "" {
+		return json.Marshal(Dict{
+			"term": Dict{
+				"package_license_set": name,
+			},
+		})
+	}
+	queries := []Dict{
+		{
+			"term": Dict{
+				"package_license_set": m.License,
+			},
+		},
+		{
+			"wildcard": Dict{
+				"package_license_set": Dict{
+					"value":            "*" + m.License + "*",
+					"case_insensitive": true,
+				},
+			},
+		},
+	}
+	return json.Marshal(Dict{
+		"dis_max": Dict{
+			"tie_breaker": 0.7,
+			"queries":     queries,
+		},
+	})
+}
+
+// unfreeClauses returns the must_not clauses that exclude unfree packages.
+func unfreeClauses() []any {
+	return []any{
+		Dict{
+			"prefix": Dict{
+				"package_license_set": unfreeLicensePrefix,
+			},
+		},
+		Dict{
+			"terms": Dict{
+				"package_license_set": UnfreeLicenses,
+			},
+		},
+	}
+}
@@ -0,0 +1,527 @@
+package nixsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// LocalClient answers searches from a [Mirror] instead of over the network.
+// It evaluates the same matchers, against the same fields and with the same
+// boosts, as the ElasticSearch queries built by [Query.Payload]. Relevance
+// scores are a simpler approximation of ElasticSearch's, so results may be
+// ordered slightly differently than they would be by search.nixos.org.
+//
+// [MatchQueryString] queries can not be answered locally.
+type LocalClient struct {
+	Mirror Mirror
+
+	mu       sync.Mutex
+	packages map[string][]Package
+}
+
+// NewLocalClient returns a client that searches the mirror in dir, or in
+// [DefaultMirrorDir] if dir is empty.
+func NewLocalClient(dir string) (*LocalClient, error) {
+	if dir == "" {
+		var err error
+		dir, err = DefaultMirrorDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &LocalClient{
+		Mirror:   Mirror{Dir: dir},
+		packages: map[string][]Package{},
+	}, nil
+}
+
+func (c *LocalClient) Search(ctx context.Context, query Query) ([]Package, error) {
+	result, err := c.SearchWithMeta(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return result.Packages(), nil
+}
+
+func (c *LocalClient) SearchWithMeta(ctx context.Context, query Query) (SearchResult, error) {
+	results, index, err := c.match(ctx, query)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	result := SearchResult{Total: len(results)}
+	for _, r := range paginate(results, query) {
+		if len(result.Hits) >= query.MaxResults {
+			break
+		}
+		result.Hits = append(result.Hits, PackageHit{
+			Package: r.pkg,
+			Score:   r.score,
+			Index:   index,
+		})
+	}
+	return result, nil
+}
+
+// SearchAll returns every matching package, ordered the same way as
+// ElasticSearch orders them: by score, then attribute name, then version.
+func (c *LocalClient) SearchAll(ctx context.Context, query Query) iter.Seq2[Package, error] {
+	return func(yield func(Package, error) bool) {
+		results, _, err := c.match(ctx, query)
+		if err != nil {
+			yield(Package{}, err)
+			return
+		}
+		for _, result := range paginate(results, query) {
+			if !yield(result.pkg, nil) {
+				return
+			}
+		}
+	}
+}
+
+// scored is a package that matched a query, and its relevance.
+type scored struct {
+	pkg   Package
+	score float64
+}
+
+// sortValues are the equivalent of [Hit.Sort] for a local result.
+func (s scored) sortValues() []any {
+	return []any{s.score, s.pkg.AttrName, s.pkg.Version}
+}
+
+// match returns every package that matches the query, sorted, and the name
+// of the index that they were read from.
+func (c *LocalClient) match(ctx context.Context, query Query) ([]scored, string, error) {
+	compiled, err := compileLocal(query)
+	if err != nil {
+		return nil, "", err
+	}
+	if !query.Flakes {
+		channel, err := c.resolveChannel(ctx, query.Channel)
+		if err != nil {
+			return nil, "", err
+		}
+		query.Channel = channel
+	}
+	packages, err := c.load(query.Index())
+	if err != nil {
+		return nil, "", err
+	}
+
+	var results []scored
+	for _, pkg := range packages {
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
+		if pkg.Type != "package" {
+			continue
+		}
+		if score, ok := compiled.score(pkg); ok {
+			results = append(results, scored{pkg: pkg, score: score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return compareSortValues(results[i].sortValues(), results[j].sortValues()) < 0
+	})
+	return results, query.Index(), nil
+}
+
+// paginate returns the results after the query's SearchAfter cursor, or
+// after its From offset.
+func paginate(results []scored, query Query) []scored {
+	if len(query.SearchAfter) != 0 {
+		start := sort.Search(len(results), func(i int) bool {
+			return compareSortValues(results[i].sortValues(), query.SearchAfter) > 0
+		})
+		return results[start:]
+	}
+	if query.From >= len(results) {
+		return nil
+	}
+	return results[max(query.From, 0):]
+}
+
+// load reads an index from the mirror, keeping it in memory for any later
+// searches.
+func (c *LocalClient) load(index string) ([]Package, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if packages, ok := c.packages[index]; ok {
+		return packages, nil
+	}
+	packages, err := c.Mirror.Read(index)
+	if err != nil {
+		return nil, err
+	}
+	if c.packages == nil {
+		c.packages = map[string][]Package{}
+	}
+	c.packages[index] = packages
+	return packages, nil
+}
+
+// localQuery is a [Query] compiled for evaluation by a [LocalClient].
+type localQuery struct {
+	matchers      []localMatcher
+	filters       []localMatcher
+	excludeUnfree bool
+	allOf         []localQuery
+	anyOf         []localQuery
+	not           []localQuery
+}
+
+func compileLocal(query Query) (localQuery, error) {
+	var lq localQuery
+	var err error
+	if lq.matchers, err = localMatchers(query.matchers()); err != nil {
+		return lq, err
+	}
+	if lq.filters, err = localMatchers(query.filters()); err != nil {
+		return lq, err
+	}
+	lq.excludeUnfree = query.ExcludeUnfree
+	for _, group := range []struct {
+		queries []Query
+		out     *[]localQuery
+	}{
+		{query.AllOf, &lq.allOf},
+		{query.AnyOf, &lq.anyOf},
+		{query.Not, &lq.not},
+	} {
+		for _, sub := range group.queries {
+			compiled, err := compileLocal(sub)
+			if err != nil {
+				return lq, err
+			}
+			*group.out = append(*group.out, compiled)
+		}
+	}
+	return lq, nil
+}
+
+// score returns how relevant the package is, and false if it doesn't match,
+// following the same rules as an ElasticSearch bool query: the scores of
+// every matcher and matching nested query are added up, and filters and
+// exclusions don't affect the score.
+func (lq localQuery) score(pkg Package) (float64, bool) {
+	var total float64
+	for _, m := range lq.matchers {
+		score, ok := m.score(pkg)
+		if !ok {
+			return 0, false
+		}
+		total += score
+	}
+	for _, f := range lq.filters {
+		if _, ok := f.score(pkg); !ok {
+			return 0, false
+		}
+	}
+	if lq.excludeUnfree && pkg.IsUnfree() {
+		return 0, false
+	}
+	for _, sub := range lq.allOf {
+		score, ok := sub.score(pkg)
+		if !ok {
+			return 0, false
+		}
+		total += score
+	}
+	if len(lq.anyOf) != 0 {
+		var matched bool
+		for _, sub := range lq.anyOf {
+			if score, ok := sub.score(pkg); ok {
+				matched = true
+				total += score
+			}
+		}
+		if !matched {
+			return 0, false
+		}
+	}
+	for _, sub := range lq.not {
+		if _, ok := sub.score(pkg); ok {
+			return 0, false
+		}
+	}
+	return total, true
+}
+
+// localMatchers returns the matchers as [localMatcher]s, or an error if any
+// of them can't be evaluated locally.
+func localMatchers(matchers []json.Marshaler) ([]localMatcher, error) {
+	out := make([]localMatcher, 0, len(matchers))
+	for _, m := range matchers {
+		lm, ok := m.(localMatcher)
+		if !ok {
+			name := strings.TrimPrefix(fmt.Sprintf("%T", m), "*nixsearch.")
+			return nil, fmt.Errorf("%s queries can not be answered from a local mirror", name)
+		}
+		out = append(out, lm)
+	}
+	return out, nil
+}
+
+// compareSortValues orders two sets of (score, attr name, version) sort
+// values, all descending, returning a negative number if a sorts before b.
+func compareSortValues(a, b []any) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		var c int
+		switch x := a[i].(type) {
+		case float64:
+			y, _ := b[i].(float64)
+			switch {
+			case x > y:
+				c = -1
+			case x < y:
+				c = 1
+			}
+		default:
+			c = -strings.Compare(fmt.Sprint(a[i]), fmt.Sprint(b[i]))
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// localMatcher is implemented by every matcher that a [LocalClient] can
+// evaluate.
+type localMatcher interface {
+	// score returns how relevant the package is, and false if the package
+	// does not match at all.
+	score(pkg Package) (float64, bool)
+}
+
+func (m MatchSearch) score(pkg Package) (float64, bool) {
+	// multi_match with type cross_fields: each term is scored against
+	// whichever field it matches best, and the term scores are added up.
+	var multiMatch float64
+	for _, term := range tokenize(m.Search) {
+		var best float64
+		for _, spec := range searchFields {
+			field, boost, partial := parseFieldBoost(spec)
+			for _, value := range fieldValues(pkg, field) {
+				for _, token := range tokenize(value) {
+					if token == term || (partial && strings.HasPrefix(token, term)) {
+						best = max(best, boost)
+					}
+				}
+			}
+		}
+		multiMatch += best
+	}
+	scores := []float64{multiMatch}
+	for _, term := range strings.Split(m.Search, " ") {
+		if wildcardMatch("*"+strings.ToLower(term)+"*", strings.ToLower(pkg.AttrName)) {
+			scores = append(scores, 1)
+		}
+	}
+	return disMax(scores...)
+}
+
+func (m MatchName) score(pkg Package) (float64, bool) {
+	return disMax(
+		boolScore(wildcardMatch(m.Name+"*", pkg.AttrName)),
+		boolScore(tokensOverlap(m.Name, pkg.Programs...)),
+	)
+}
+
+func (m MatchAttrNames) score(pkg Package) (float64, bool) {
+	matched := slices.Contains(m.AttrNames, pkg.AttrName)
+	return boolScore(matched), matched
+}
+
+func (m MatchProgram) score(pkg Package) (float64, bool) {
+	var wildcard bool
+	for _, program := range pkg.Programs {
+		wildcard = wildcard || wildcardMatch(m.Program+"*", program)
+	}
+	return disMax(
+		boolScore(wildcard),
+		boolScore(tokensOverlap(m.Program, pkg.Programs...)),
+	)
+}
+
+func (m MatchVersion) score(pkg Package) (float64, bool) {
+	return disMax(
+		boolScore(wildcardMatch(m.Version+"*", pkg.Version)),
+		boolScore(tokensOverlap(m.Version, pkg.Version)),
+	)
+}
+
+func (m MatchLicense) score(pkg Package) (float64, bool) {
+	var scores []float64
+	name := m.fullName()
+	for _, license := range pkg.Licenses {
+		if name != "" {
+			scores = append(scores, boolScore(license.FullName == name))
+			continue
+		}
+		scores = append(scores,
+			boolScore(license.FullName == m.License),
+			boolScore(strings.Contains(strings.ToLower(license.FullName), strings.ToLower(m.License))),
+		)
+	}
+	return disMax(scores...)
+}
+
+func (m MatchMaintainer) score(pkg Package) (float64, bool) {
+	var scores []float64
+	for _, maintainer := range pkg.Maintainers {
+		scores = append(scores,
+			boolScore(maintainer.GitHub != "" && strings.EqualFold(maintainer.GitHub, m.handle())),
+			boolScore(phraseMatch(m.Maintainer, maintainer.Name)),
+		)
+	}
+	for _, name := range pkg.MaintainersSet {
+		scores = append(scores, boolScore(phraseMatch(m.Maintainer, name)))
+	}
+	return disMax(scores...)
+}
+
+func (m MatchPlatform) score(pkg Package) (float64, bool) {
+	matched := pkg.AvailableOn(m.Platform)
+	return boolScore(matched), matched
+}
+
+// disMax combines scores the same way as an ElasticSearch dis_max query with
+// a tie_breaker of 0.7: the best score, plus 0.7 times each of the others.
+func disMax(scores ...float64) (float64, bool) {
+	var best, sum float64
+	for _, score := range scores {
+		best = max(best, score)
+		sum += score
+	}
+	return best + 0.7*(sum-best), best > 0
+}
+
+func boolScore(matched bool) float64 {
+	if matched {
+		return 1
+	}
+	return 0
+}
+
+// parseFieldBoost parses one of the [searchFields], like
+// "package_programs.*^5.4", into its field name, boost, and whether it is
+// the ngram subfield that allows partial matches.
+func parseFieldBoost(spec string) (string, float64, bool) {
+	field, boostStr, _ := strings.Cut(spec, "^")
+	boost, err := strconv.ParseFloat(boostStr, 64)
+	if err != nil {
+		boost = 1
+	}
+	field, partial := strings.CutSuffix(field, ".*")
+	return field, boost, partial
+}
+
+// fieldValues returns the values of the index field with the given name.
+func fieldValues(pkg Package, field string) []string {
+	switch field {
+	case "package_attr_name":
+		return []string{pkg.AttrName}
+	case "package_programs":
+		return pkg.Programs
+	case "package_pname":
+		return []string{pkg.Name}
+	case "package_description":
+		return []string{pkg.Description}
+	case "package_pversion":
+		return []string{pkg.Version}
+	case "flake_name":
+		return []string{pkg.FlakeName}
+	case "flake_resolved":
+		r := pkg.FlakeResolved
+		return []string{r.Type, r.Owner, r.Repo, r.URL}
+	default:
+		return nil
+	}
+}
+
+// tokenize approximates ElasticSearch's standard analyzer: lowercase, and
+// split into words on anything that isn't a letter, a digit, or punctuation
+// that can appear inside of a word, so that "python3.12" and "3.11.9" are
+// single tokens, but "ripgrep-all" is two.
+func tokenize(s string) []string {
+	var tokens []string
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '_' && r != '\''
+	})
+	for _, word := range words {
+		if word = strings.Trim(word, "._'"); word != "" {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// tokensOverlap reports whether any token of the query appears in any of the
+// values, like an ElasticSearch match query.
+func tokensOverlap(query string, values ...string) bool {
+	terms := tokenize(query)
+	for _, value := range values {
+		for _, token := range tokenize(value) {
+			for _, term := range terms {
+				if token == term {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// phraseMatch reports whether the tokens of the phrase appear in value, next
+// to each other and in order, like an ElasticSearch match_phrase query.
+func phraseMatch(phrase, value string) bool {
+	terms, tokens := tokenize(phrase), tokenize(value)
+	if len(terms) == 0 {
+		return false
+	}
+	for i := 0; i+len(terms) <= len(tokens); i++ {
+		if slices.Equal(terms, tokens[i:i+len(terms)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardMatch reports whether s matches an ElasticSearch wildcard pattern,
+// where "*" matches any sequence of characters and "?" matches any single
+// character.
+func wildcardMatch(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	pi, si := 0, 0
+	star, mark := -1, 0
+	for si < len(str) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == str[si]):
+			pi++
+			si++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, si
+			pi++
+		case star != -1:
+			pi = star + 1
+			mark++
+			si = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
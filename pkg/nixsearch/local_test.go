@@ -0,0 +1,331 @@
+package nixsearch
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+func packageSeq(packages ...Package) iter.Seq2[Package, error] {
+	return func(yield func(Package, error) bool) {
+		for _, pkg := range packages {
+			if !yield(pkg, nil) {
+				return
+			}
+		}
+	}
+}
+
+func attrNames(packages []Package) []string {
+	var names []string
+	for _, pkg := range packages {
+		names = append(names, pkg.AttrName)
+	}
+	return names
+}
+
+func newTestLocalClient(t *testing.T) *LocalClient {
+	t.Helper()
+	client, err := NewLocalClient(t.TempDir())
+	assert.NoError(t, err)
+	info, err := client.Mirror.Write("nixos-unstable", packageSeq(
+		Package{Type: "package", AttrName: "ripgrep", Name: "ripgrep", Version: "14.1.0", Programs: []string{"rg"}, Description: "A fast line-oriented search tool", Platforms: []string{"x86_64-linux", "aarch64-darwin"}},
+		Package{Type: "package", AttrName: "ripgrep-all", Name: "ripgrep-all", Version: "0.10.6", Programs: []string{"rga", "rga-preproc"}, Description: "ripgrep, but also search in PDFs", Platforms: []string{"x86_64-linux"}},
+		Package{Type: "package", AttrName: "python312", Name: "python3", Version: "3.12.4", Programs: []string{"python", "python3", "python3.12"}},
+		Package{Type: "package", AttrName: "python311", Name: "python3", Version: "3.11.9", Programs: []string{"python", "python3", "python3.11"}},
+		Package{Type: "package", AttrName: "python311", Name: "python3", Version: "3.11.9"}, // duplicate
+		Package{Type: "package", AttrName: "go", Name: "go", Version: "1.22.5", Programs: []string{"go", "gofmt"}},
+	))
+	assert.NoError(t, err)
+	check.Equal(t, 5, info.Packages)
+	return client
+}
+
+func TestMirrorRoundTrip(t *testing.T) {
+	t.Parallel()
+	client := newTestLocalClient(t)
+
+	infos, err := client.Mirror.List()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(infos))
+	check.Equal(t, "nixos-unstable", infos[0].Index)
+	check.Equal(t, MirrorFormatVersion, infos[0].FormatVersion)
+
+	packages, err := client.Mirror.Read("nixos-unstable")
+	assert.NoError(t, err)
+	check.Equal(t, []string{"ripgrep", "ripgrep-all", "python312", "python311", "go"}, attrNames(packages))
+
+	_, err = client.Mirror.Read("nixos-24.05")
+	check.True(t, errors.Is(err, ErrNotSynced))
+
+	// A failed sync leaves the existing copy alone.
+	failure := errors.New("connection reset")
+	_, err = client.Mirror.Write("nixos-unstable", func(yield func(Package, error) bool) {
+		_ = yield(Package{Type: "package", AttrName: "hello"}, nil) && yield(Package{}, failure)
+	})
+	check.True(t, errors.Is(err, failure))
+	packages, err = client.Mirror.Read("nixos-unstable")
+	assert.NoError(t, err)
+	check.Equal(t, 5, len(packages))
+}
+
+func TestMirrorSync(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	var searched []string
+	remote, err := NewElasticSearchClientWithOptions(ClientOptions{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			var r Response
+			switch req.URL.Path {
+			case "/_aliases":
+				return jsonResponse(t, http.StatusOK, Dict{
+					"nixos-43-unstable-aaa": Dict{"aliases": Dict{"latest-43-nixos-unstable": Dict{}}},
+					"nixos-43-24.05-bbb":    Dict{"aliases": Dict{"latest-43-nixos-24.05": Dict{}}},
+					"nixos-43-24.11-ccc":    Dict{"aliases": Dict{"latest-43-nixos-24.11": Dict{}}},
+				}), nil
+			case "/_search/scroll":
+				// Every package fits on the first page.
+			default:
+				searched = append(searched, req.URL.Path)
+				r.ScrollID = "scroll-1"
+				r.Hits.Hits = []Hit{{Package: Package{Type: "package", AttrName: "ripgrep", Version: "14.1.1"}}}
+			}
+			return jsonResponse(t, http.StatusOK, r), nil
+		}),
+	})
+	assert.NoError(t, err)
+
+	// A symbolic channel is synced as the release that it refers to.
+	dir := t.TempDir()
+	local, err := NewLocalClient(dir)
+	assert.NoError(t, err)
+	info, err := local.Mirror.Sync(ctx, remote, Query{Channel: "stable", MaxResults: 100}, nil)
+	assert.NoError(t, err)
+	check.Equal(t, []string{"/latest-*-nixos-24.11/_search"}, searched)
+	check.Equal(t, "nixos-24.11", info.Index)
+	check.Equal(t, 1, info.Packages)
+
+	// So that it can be searched offline with the same name.
+	packages, err := local.Search(ctx, Query{Channel: "stable", MaxResults: 10, Name: &MatchName{Name: "ripgrep"}})
+	assert.NoError(t, err)
+	check.Equal(t, []string{"ripgrep"}, attrNames(packages))
+}
+
+func TestLocalClientMatchers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := newTestLocalClient(t)
+
+	search := func(query Query) []string {
+		t.Helper()
+		query.Channel = "unstable"
+		query.MaxResults = 10
+		packages, err := client.Search(ctx, query)
+		assert.NoError(t, err)
+		return attrNames(packages)
+	}
+
+	// Results are sorted by score, then by attr name descending, like the
+	// remote index.
+	check.Equal(t, []string{"python312", "python311"}, search(Query{Name: &MatchName{Name: "python"}}))
+	check.Equal(t, []string{"ripgrep-all", "ripgrep"}, search(Query{Name: &MatchName{Name: "ripgrep"}}))
+	// "rg" is an exact match for ripgrep's program, and a prefix of
+	// ripgrep-all's "rga".
+	check.Equal(t, []string{"ripgrep", "ripgrep-all"}, search(Query{Program: &MatchProgram{Program: "rg"}}))
+	check.Equal(t, []string{"ripgrep-all"}, search(Query{Program: &MatchProgram{Program: "rga-*"}}))
+	check.Equal(t, []string{"python311"}, search(Query{Version: &MatchVersion{Version: "3.11"}}))
+	// Matching a program exactly scores higher than only matching the
+	// description or part of the attr name.
+	check.Equal(t, []string{"go"}, search(Query{Search: &MatchSearch{Search: "gofmt"}}))
+	results := search(Query{Search: &MatchSearch{Search: "rg"}})
+	check.Equal(t, "ripgrep", results[0])
+	// Matchers are combined.
+	check.Equal(t, []string{"python312"}, search(Query{
+		Name:    &MatchName{Name: "python"},
+		Program: &MatchProgram{Program: "python3.12"},
+	}))
+
+	_, err := client.Search(ctx, Query{
+		Channel:     "unstable",
+		MaxResults:  10,
+		QueryString: &MatchQueryString{QueryString: "package_programs:rg"},
+	})
+	check.Error(t, err)
+}
+
+func TestLocalClientPlatform(t *testing.T) {
+	t.Parallel()
+	client := newTestLocalClient(t)
+
+	query := Query{
+		MaxResults: 10,
+		Channel:    "unstable",
+		Search:     &MatchSearch{Search: "ripgrep"},
+		Platform:   &MatchPlatform{Platform: "aarch64-darwin"},
+	}
+	packages, err := client.Search(context.Background(), query)
+	assert.NoError(t, err)
+	check.Equal(t, []string{"ripgrep"}, attrNames(packages))
+
+	// Packages that don't list any platforms are available everywhere.
+	query.Search = nil
+	query.Program = &MatchProgram{Program: "gofmt"}
+	packages, err = client.Search(context.Background(), query)
+	assert.NoError(t, err)
+	check.Equal(t, []string{"go"}, attrNames(packages))
+}
+
+func TestLocalClientLicense(t *testing.T) {
+	t.Parallel()
+	client, err := NewLocalClient(t.TempDir())
+	assert.NoError(t, err)
+	_, err = client.Mirror.Write("nixos-unstable", packageSeq(
+		Package{Type: "package", AttrName: "terraform", Name: "terraform", Version: "1.9.2", Licenses: []License{{FullName: "Business Source License 1.1", URL: "https://spdx.org/licenses/BUSL-1.1.html"}}},
+		Package{Type: "package", AttrName: "opentofu", Name: "opentofu", Version: "1.7.2", Licenses: []License{{FullName: "Mozilla Public License 2.0", URL: "https://spdx.org/licenses/MPL-2.0.html"}}},
+	))
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	query := Query{MaxResults: 10, Channel: "unstable", License: &MatchLicense{License: "mpl-2.0"}}
+	packages, err := client.Search(ctx, query)
+	assert.NoError(t, err)
+	check.Equal(t, []string{"opentofu"}, attrNames(packages))
+
+	// SPDX ids are matched by the full name they stand for, not the URL,
+	// the same as they are by ElasticSearch.
+	query.License.License = "BUSL-1.1"
+	packages, err = client.Search(ctx, query)
+	assert.NoError(t, err)
+	check.Equal(t, []string{"terraform"}, attrNames(packages))
+
+	// As is any part of a license's name.
+	query.License.License = "public lic"
+	packages, err = client.Search(ctx, query)
+	assert.NoError(t, err)
+	check.Equal(t, []string{"opentofu"}, attrNames(packages))
+
+	query = Query{MaxResults: 10, Channel: "unstable", Search: &MatchSearch{Search: "terraform opentofu"}}
+	packages, err = client.Search(ctx, query)
+	assert.NoError(t, err)
+	check.Equal(t, 2, len(packages))
+	query.ExcludeUnfree = true
+	packages, err = client.Search(ctx, query)
+	assert.NoError(t, err)
+	check.Equal(t, []string{"opentofu"}, attrNames(packages))
+}
+
+func TestLocalClientLicenseSPDX(t *testing.T) {
+	t.Parallel()
+	client, err := NewLocalClient(t.TempDir())
+	assert.NoError(t, err)
+	_, err = client.Mirror.Write("nixos-unstable", packageSeq(
+		Package{Type: "package", AttrName: "ripgrep", Licenses: []License{{FullName: "MIT License"}}},
+		Package{Type: "package", AttrName: "zlib-ng", Licenses: []License{{FullName: "MIT No Attribution"}}},
+		Package{Type: "package", AttrName: "bash", Licenses: []License{{FullName: "GNU General Public License v3.0 or later"}}},
+		Package{Type: "package", AttrName: "glib", Licenses: []License{{FullName: "GNU Lesser General Public License v3.0 or later"}}},
+		Package{Type: "package", AttrName: "gitea", Licenses: []License{{FullName: "GNU Affero General Public License v3.0 or later"}}},
+	))
+	assert.NoError(t, err)
+
+	search := func(license string) []string {
+		t.Helper()
+		query := Query{MaxResults: 10, Channel: "unstable", License: &MatchLicense{License: license}}
+		packages, err := client.Search(context.Background(), query)
+		assert.NoError(t, err)
+		return attrNames(packages)
+	}
+	// SPDX ids only match that exact license.
+	check.Equal(t, []string{"ripgrep"}, search("MIT"))
+	check.Equal(t, []string{"bash"}, search("GPL-3.0-or-later"))
+	check.Equal(t, []string{"glib"}, search("lgpl-3.0-or-later"))
+	check.Equal(t, []string{"gitea"}, search("AGPL-3.0-or-later"))
+	// Anything else matches every license whose name contains it.
+	check.Equal(t, []string{"zlib-ng"}, search("MIT No"))
+	check.Equal(t, []string{"glib", "gitea", "bash"}, search("General Public License v3.0"))
+}
+
+func TestLocalClientMaintainer(t *testing.T) {
+	t.Parallel()
+	client, err := NewLocalClient(t.TempDir())
+	assert.NoError(t, err)
+	_, err = client.Mirror.Write("nixos-unstable", packageSeq(
+		Package{Type: "package", AttrName: "ripgrep", Maintainers: []Maintainer{{Name: "Tom Hall", GitHub: "tomhall"}}, MaintainersSet: []string{"Tom Hall"}},
+		Package{Type: "package", AttrName: "fd", Maintainers: []Maintainer{{Name: "Jane Doe", GitHub: "janedoe"}}, MaintainersSet: []string{"Jane Doe"}},
+	))
+	assert.NoError(t, err)
+
+	for _, maintainer := range []string{"tomhall", "@TomHall", "Tom Hall", "hall"} {
+		query := Query{MaxResults: 10, Channel: "unstable", Maintainer: &MatchMaintainer{Maintainer: maintainer}}
+		packages, err := client.Search(context.Background(), query)
+		assert.NoError(t, err)
+		check.Equal(t, []string{"ripgrep"}, attrNames(packages))
+	}
+}
+
+func TestLocalClientComposition(t *testing.T) {
+	t.Parallel()
+	client := newTestLocalClient(t)
+	ctx := context.Background()
+
+	query := Query{
+		MaxResults: 10,
+		Channel:    "unstable",
+		AnyOf: []Query{
+			{Program: &MatchProgram{Program: "rg"}},
+			{Program: &MatchProgram{Program: "python"}},
+		},
+		Not: []Query{
+			{Name: &MatchName{Name: "python311"}},
+		},
+	}
+	packages, err := client.Search(ctx, query)
+	assert.NoError(t, err)
+	check.Equal(t, []string{"ripgrep", "python312", "ripgrep-all"}, attrNames(packages))
+
+	query.AllOf = []Query{{Version: &MatchVersion{Version: "3.*"}}}
+	packages, err = client.Search(ctx, query)
+	assert.NoError(t, err)
+	check.Equal(t, []string{"python312"}, attrNames(packages))
+}
+
+func TestLocalClientPagination(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := newTestLocalClient(t)
+
+	query := Query{Channel: "unstable", MaxResults: 2, Name: &MatchName{Name: "*"}}
+	var all []Package
+	for pkg, err := range client.SearchAll(ctx, query) {
+		assert.NoError(t, err)
+		all = append(all, pkg)
+	}
+	check.Equal(t, []string{"ripgrep-all", "ripgrep", "python312", "python311", "go"}, attrNames(all))
+
+	query.From = 2
+	page, err := client.Search(ctx, query)
+	assert.NoError(t, err)
+	check.Equal(t, []string{"python312", "python311"}, attrNames(page))
+
+	query.From = 0
+	query.SearchAfter = []any{1.0, "python311", "3.11.9"}
+	page, err = client.Search(ctx, query)
+	assert.NoError(t, err)
+	check.Equal(t, []string{"go"}, attrNames(page))
+}
+
+func TestWildcardMatch(t *testing.T) {
+	t.Parallel()
+	check.True(t, wildcardMatch("python3Packages.*", "python3Packages.requests"))
+	check.True(t, wildcardMatch("*grep*", "ripgrep-all"))
+	check.True(t, wildcardMatch("go?", "go1"))
+	check.True(t, wildcardMatch("*", ""))
+	check.False(t, wildcardMatch("go?", "go"))
+	check.False(t, wildcardMatch("python3Packages.*", "python3"))
+	check.False(t, wildcardMatch("*grep", "ripgrep-all"))
+}
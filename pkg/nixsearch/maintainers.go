@@ -0,0 +1,45 @@
+package nixsearch
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// MatchMaintainer filters packages by who maintains them, either by GitHub
+// handle, like "peterldowns" or "@peterldowns", or by name.
+type MatchMaintainer struct {
+	Maintainer string
+}
+
+// handle returns the maintainer without any leading "@".
+func (m MatchMaintainer) handle() string {
+	return strings.TrimPrefix(m.Maintainer, "@")
+}
+
+func (m MatchMaintainer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Dict{
+		"dis_max": Dict{
+			"tie_breaker": 0.7,
+			"queries": []Dict{
+				{
+					"term": Dict{
+						"package_maintainers.github": Dict{
+							"value":            m.handle(),
+							"case_insensitive": true,
+						},
+					},
+				},
+				{
+					"match_phrase": Dict{
+						"package_maintainers_set": m.Maintainer,
+					},
+				},
+				{
+					"match_phrase": Dict{
+						"package_maintainers.name": m.Maintainer,
+					},
+				},
+			},
+		},
+	})
+}
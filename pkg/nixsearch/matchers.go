@@ -6,6 +6,27 @@ import (
 	"strings"
 )
 
+// searchFields are the fields (and their boosts) that [MatchSearch] queries,
+// taken from the upstream search.nixos.org frontend. The ".*" variants are
+// the ngram subfields that allow partial matches, at a lower boost.
+var searchFields = []string{ //nolint:gochecknoglobals
+	"package_attr_name^9",
+	"package_attr_name.*^5.3999999999999995",
+	"package_programs^9",
+	"package_programs.*^5.3999999999999995",
+	"package_pname^6",
+	"package_pname.*^3.5999999999999996",
+	"package_description^1.3",
+	"package_description.*^0.78",
+	"package_pversion^1.3",
+	"package_pversion.*^0.78",
+	"package_longDescription^1",
+	"package_longDescription.*^0.6",
+	"flake_name^0.5",
+	"flake_name.*^0.3",
+	"flake_resolved.*^99",
+}
+
 type MatchSearch struct {
 	Search string
 }
@@ -15,26 +36,10 @@ func (m MatchSearch) MarshalJSON() ([]byte, error) {
 	queries := []Dict{
 		{
 			"multi_match": Dict{
-				"type":  "cross_fields",
-				"_name": multiMatchName,
-				"query": m.Search,
-				"fields": []string{
-					"package_attr_name^9",
-					"package_attr_name.*^5.3999999999999995",
-					"package_programs^9",
-					"package_programs.*^5.3999999999999995",
-					"package_pname^6",
-					"package_pname.*^3.5999999999999996",
-					"package_description^1.3",
-					"package_description.*^0.78",
-					"package_pversion^1.3",
-					"package_pversion.*^0.78",
-					"package_longDescription^1",
-					"package_longDescription.*^0.6",
-					"flake_name^0.5",
-					"flake_name.*^0.3",
-					"flake_resolved.*^99",
-				},
+				"type":   "cross_fields",
+				"_name":  multiMatchName,
+				"query":  m.Search,
+				"fields": searchFields,
 			},
 		},
 	}
@@ -82,6 +87,21 @@ func (m MatchName) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// MatchAttrNames matches packages whose attribute name is exactly one of the
+// given names, unlike [MatchName], which matches any attribute name that
+// starts with it.
+type MatchAttrNames struct {
+	AttrNames []string
+}
+
+func (m MatchAttrNames) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Dict{
+		"terms": Dict{
+			"package_attr_name": m.AttrNames,
+		},
+	})
+}
+
 type MatchProgram struct {
 	Program string
 }
@@ -134,14 +154,21 @@ func (m MatchVersion) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// MatchQueryString filters by a query in ElasticSearch's QueryString syntax,
+// after checking it and rewriting any field aliases with
+// [RewriteQueryString].
 type MatchQueryString struct {
 	QueryString string
 }
 
 func (m MatchQueryString) MarshalJSON() ([]byte, error) {
+	query, err := RewriteQueryString(m.QueryString)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadQueryString, err)
+	}
 	return json.Marshal(Dict{
 		"query_string": Dict{
-			"query": m.QueryString,
+			"query": query,
 		},
 	})
 }
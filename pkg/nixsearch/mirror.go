@@ -0,0 +1,223 @@
+package nixsearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MirrorFormatVersion is the version of the on-disk layout of a [Mirror]. It
+// is part of the path of every synced index, so that a release which changes
+// the layout never tries to read data written by an older one; users just
+// need to sync again.
+const MirrorFormatVersion = 1
+
+// Mirror is a local copy of one or more search indexes, stored in a
+// directory like this:
+//
+//	<Dir>/v1/nixos-unstable/info.json
+//	<Dir>/v1/nixos-unstable/packages.jsonl
+//	<Dir>/v1/nixos-24.05/info.json
+//	<Dir>/v1/nixos-24.05/packages.jsonl
+//	<Dir>/v1/group-manual/...
+//
+// where each index directory is named after [Query.Index].
+type Mirror struct {
+	Dir string
+}
+
+// MirrorInfo describes a single synced index.
+type MirrorInfo struct {
+	FormatVersion int       `json:"format_version"`
+	Index         string    `json:"index"`
+	SyncedAt      time.Time `json:"synced_at"`
+	Packages      int       `json:"packages"`
+}
+
+// ErrNotSynced is returned when reading an index that has not been synced to
+// the mirror.
+var ErrNotSynced = errors.New("index has not been synced")
+
+// DefaultMirrorDir returns $XDG_DATA_HOME/nix-search, falling back to
+// ~/.local/share/nix-search if $XDG_DATA_HOME is not set.
+func DefaultMirrorDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "nix-search"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "nix-search"), nil
+}
+
+func (m Mirror) root() string {
+	return filepath.Join(m.Dir, "v"+strconv.Itoa(MirrorFormatVersion))
+}
+
+// Write replaces the mirrored copy of an index with the given packages,
+// removing any duplicates. The previous copy is only replaced once every
+// package has been written, so a failed or interrupted sync leaves the
+// existing copy intact.
+func (m Mirror) Write(index string, packages iter.Seq2[Package, error]) (MirrorInfo, error) {
+	info := MirrorInfo{
+		FormatVersion: MirrorFormatVersion,
+		Index:         index,
+	}
+	if err := os.MkdirAll(m.root(), 0o755); err != nil {
+		return info, err
+	}
+	tmp, err := os.MkdirTemp(m.root(), ".tmp-"+index+"-*")
+	if err != nil {
+		return info, err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := writePackages(filepath.Join(tmp, "packages.jsonl"), packages, &info); err != nil {
+		return info, err
+	}
+	info.SyncedAt = time.Now().UTC()
+	b, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return info, err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "info.json"), b, 0o644); err != nil {
+		return info, err
+	}
+
+	dest := filepath.Join(m.root(), index)
+	old := tmp + "-old"
+	if err := os.Rename(dest, old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return info, err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return info, err
+	}
+	return info, os.RemoveAll(old)
+}
+
+// Sync downloads every package that the query matches with
+// [ElasticSearchClient.Scroll], and writes them to the mirror under the
+// query's index. Symbolic channels like "stable" are resolved first, so that
+// the copy is named after the release that was downloaded, like
+// "nixos-24.05", and a [LocalClient] resolves "stable" to it later. If
+// progress is set, the packages are passed through it as they're downloaded.
+func (m Mirror) Sync(
+	ctx context.Context,
+	client *ElasticSearchClient,
+	query Query,
+	progress func(iter.Seq2[Package, error]) iter.Seq2[Package, error],
+) (MirrorInfo, error) {
+	query, err := client.resolveQuery(ctx, query)
+	if err != nil {
+		return MirrorInfo{}, err
+	}
+	packages := client.Scroll(ctx, query)
+	if progress != nil {
+		packages = progress(packages)
+	}
+	return m.Write(query.Index(), packages)
+}
+
+func writePackages(path string, packages iter.Seq2[Package, error], info *MirrorInfo) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	seen := map[string]struct{}{}
+	for pkg, err := range packages {
+		if err != nil {
+			return err
+		}
+		id := pkg.ID()
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		if err := enc.Encode(pkg); err != nil {
+			return err
+		}
+		info.Packages++
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Info returns the description of a synced index, or [ErrNotSynced].
+func (m Mirror) Info(index string) (MirrorInfo, error) {
+	var info MirrorInfo
+	b, err := os.ReadFile(filepath.Join(m.root(), index, "info.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return info, fmt.Errorf("%w: %s", ErrNotSynced, index)
+	}
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(b, &info)
+	return info, err
+}
+
+// Read returns every package in a synced index, or [ErrNotSynced].
+func (m Mirror) Read(index string) ([]Package, error) {
+	if _, err := m.Info(index); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(m.root(), index, "packages.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var packages []Package
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var pkg Package
+		if err := dec.Decode(&pkg); err != nil {
+			return nil, fmt.Errorf("corrupt mirror of %s: %w", index, err)
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+// List returns the descriptions of every synced index, sorted by name.
+func (m Mirror) List() ([]MirrorInfo, error) {
+	entries, err := os.ReadDir(m.root())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var infos []MirrorInfo
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name()[0] == '.' {
+			continue
+		}
+		info, err := m.Info(entry.Name())
+		if errors.Is(err, ErrNotSynced) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Index < infos[j].Index
+	})
+	return infos, nil
+}
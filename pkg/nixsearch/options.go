@@ -0,0 +1,135 @@
+package nixsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OptionQuery searches for NixOS options rather than packages. At least one of
+// Search or Name must be set.
+type OptionQuery struct {
+	// Meta
+	MaxResults int    // How many results, max, should be returned
+	From       int    // Offset of the first result to return
+	Channel    string // which nix-channel index to look at. mutually exclusive with Flakes.
+	Flakes     bool   // if true, uses the flakes index instead. mutually exclusive with Channel.
+
+	// Search matches the same way that search.nixos.org/options does.
+	Search string
+	// Name filters by options whose names start with this prefix, which may
+	// contain wildcards, like "services.nginx." or "services.*.enable".
+	Name string
+}
+
+// Index returns the name of the index that this query searches, see
+// [Query.Index].
+func (q OptionQuery) Index() string {
+	return indexName(q.Channel, q.Flakes)
+}
+
+// IsEmpty returns false if any match has been set
+func (q OptionQuery) IsEmpty() bool {
+	return q.Search == "" && q.Name == ""
+}
+
+// Payload returns the JSON request body to send to ElasticSearch.
+func (q OptionQuery) Payload() ([]byte, error) {
+	must := []any{
+		Dict{
+			"match": Dict{
+				"type": "option",
+			},
+		},
+	}
+	if q.Search != "" {
+		queries := []Dict{
+			{
+				"multi_match": Dict{
+					"type":  "cross_fields",
+					"query": q.Search,
+					"fields": []string{
+						"option_name^6",
+						"option_name.*^3.5999999999999996",
+						"option_description^1",
+						"option_description.*^0.6",
+						"flake_name^0.5",
+						"flake_name.*^0.3",
+					},
+				},
+			},
+		}
+		for _, term := range strings.Split(q.Search, " ") {
+			queries = append(queries, Dict{
+				"wildcard": Dict{
+					"option_name": Dict{
+						"value":            fmt.Sprintf("*%s*", term),
+						"case_insensitive": true,
+					},
+				},
+			})
+		}
+		must = append(must, Dict{
+			"dis_max": Dict{
+				"tie_breaker": 0.7,
+				"queries":     queries,
+			},
+		})
+	}
+	if q.Name != "" {
+		must = append(must, Dict{
+			"wildcard": Dict{
+				"option_name": Dict{
+					"value": q.Name + "*",
+				},
+			},
+		})
+	}
+	return json.Marshal(Dict{
+		"from": q.From,
+		"size": q.MaxResults,
+		"sort": []Dict{
+			{"_score": "desc"},
+			{"option_name": "desc"},
+		},
+		"query": Dict{
+			"bool": Dict{
+				"must": must,
+			},
+		},
+	})
+}
+
+// OptionSearcher is implemented by clients that can search for NixOS options.
+type OptionSearcher interface {
+	SearchOptions(ctx context.Context, query OptionQuery) ([]Option, error)
+}
+
+// SearchOptions returns the NixOS options matching the query.
+func (c ElasticSearchClient) SearchOptions(ctx context.Context, query OptionQuery) ([]Option, error) {
+	if !query.Flakes {
+		channel, err := c.resolveChannel(ctx, query.Channel)
+		if err != nil {
+			return nil, err
+		}
+		query.Channel = channel
+	}
+	payload, err := query.Payload()
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.do(ctx, http.MethodPost, c.indexPath(query.Index())+"/_search", payload)
+	if err != nil {
+		return nil, err
+	}
+	var options []Option
+	for _, hit := range r.Hits.Hits {
+		if hit.Option.Type != "option" {
+			continue
+		}
+		options = append(options, hit.Option)
+	}
+	return options, nil
+}
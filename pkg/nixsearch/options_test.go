@@ -0,0 +1,67 @@
+package nixsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+func TestSearchOptions(t *testing.T) {
+	t.Parallel()
+
+	var payload Dict
+	client, err := NewElasticSearchClientWithOptions(ClientOptions{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			assert.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			return jsonResponse(t, http.StatusOK, Dict{
+				"hits": Dict{
+					"hits": []Dict{
+						{
+							"_id": "1",
+							"_source": Dict{
+								"type":               "option",
+								"option_name":        "services.nginx.enable",
+								"option_description": "Whether to enable Nginx Web Server.",
+								"option_type":        "boolean",
+								"option_default":     "false",
+								"option_example":     "true",
+								"option_source":      "nixos/modules/services/web-servers/nginx/default.nix",
+							},
+						},
+						{
+							"_id":     "2",
+							"_source": Dict{"type": "package", "package_attr_name": "nginx"},
+						},
+					},
+				},
+			}), nil
+		}),
+	})
+	assert.NoError(t, err)
+
+	options, err := client.SearchOptions(context.Background(), OptionQuery{
+		Channel:    "unstable",
+		MaxResults: 10,
+		Name:       "services.nginx.",
+	})
+	assert.NoError(t, err)
+	check.Equal(t, []Option{{
+		Type:        "option",
+		Name:        "services.nginx.enable",
+		Description: "Whether to enable Nginx Web Server.",
+		OptionType:  "boolean",
+		Default:     "false",
+		Example:     "true",
+		Source:      "nixos/modules/services/web-servers/nginx/default.nix",
+	}}, options)
+
+	must := payload["query"].(map[string]any)["bool"].(map[string]any)["must"]
+	check.Equal[any](t, []any{
+		map[string]any{"match": map[string]any{"type": "option"}},
+		map[string]any{"wildcard": map[string]any{"option_name": map[string]any{"value": "services.nginx.*"}}},
+	}, must)
+}
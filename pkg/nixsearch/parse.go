@@ -0,0 +1,289 @@
+package nixsearch
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixversion"
+)
+
+// queryFields are the fields that can be used in the query language, and how
+// each one sets a matcher on a [Query].
+var queryFields = map[string]func(q *Query, value string) error{ //nolint:gochecknoglobals
+	"program": func(q *Query, value string) error {
+		q.Program = &MatchProgram{Program: strings.TrimSuffix(value, "*")}
+		return nil
+	},
+	"name": func(q *Query, value string) error {
+		q.Name = &MatchName{Name: strings.TrimSuffix(value, "*")}
+		return nil
+	},
+	"version": func(q *Query, value string) error {
+		q.Version = &MatchVersion{Version: strings.TrimSuffix(value, "*")}
+		return nil
+	},
+	"license": func(q *Query, value string) error {
+		q.License = &MatchLicense{License: value}
+		return nil
+	},
+	"maintainer": func(q *Query, value string) error {
+		q.Maintainer = &MatchMaintainer{Maintainer: value}
+		return nil
+	},
+	"platform": func(q *Query, value string) error {
+		if value == "current" {
+			platform, ok := CurrentPlatform()
+			if !ok {
+				return errors.New("nix doesn't support this machine's platform")
+			}
+			value = platform
+		}
+		q.Platform = &MatchPlatform{Platform: value}
+		return nil
+	},
+}
+
+// ParseError describes a problem with a query passed to [ParseQuery].
+type ParseError struct {
+	Query string
+	// Pos is the byte offset into Query where the problem is.
+	Pos int
+	Msg string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("invalid query at column %d: %s", e.Pos+1, e.Msg)
+}
+
+// ParseQuery parses a query like
+//
+//	program:rg name:ripgrep* -license:unfree version:>=14 platform:aarch64-darwin
+//
+// Each term is either a "field:value" pair, using one of the fields
+// "program", "name", "version", "license", "maintainer", or "platform", or
+// free text that is searched the same way as [MatchSearch]. Terms starting
+// with "-" exclude results instead, and "-license:unfree" excludes every
+// unfree package. Values can be quoted, like maintainer:"Peter Downs". Every
+// term must match, except that repeating a field matches any of its values.
+//
+// Versions that start with a comparison, like version:>=14 or
+// version:"~1.22", are parsed with [nixversion.ParseConstraint] and set
+// [Query.VersionRange] instead of matching a prefix. Every comparison must
+// be satisfied, so version:>=1.20 version:<1.23 is a range, and they can't
+// be excluded with "-".
+//
+// Only the matchers of the returned query are set, not its channel or
+// pagination. Problems are reported as a [ParseError].
+func ParseQuery(s string) (Query, error) {
+	var query Query
+	var search []string
+	var versionRanges []string
+	values := map[string][]string{}
+	var fields []string // in order of first appearance, for stable output
+
+	p := parser{input: s}
+	for {
+		t, err := p.next()
+		if err != nil {
+			return Query{}, err
+		}
+		if t == nil {
+			break
+		}
+		if t.field == "" {
+			if t.negated {
+				query.Not = append(query.Not, Query{Search: &MatchSearch{Search: t.value}})
+			} else {
+				search = append(search, t.value)
+			}
+			continue
+		}
+		set, ok := queryFields[t.field]
+		if !ok {
+			msg := fmt.Sprintf("unknown field %q", t.field)
+			if suggestion := closest(t.field, queryFieldNames()); suggestion != "" {
+				msg += fmt.Sprintf(", did you mean %q?", suggestion)
+			}
+			return Query{}, ParseError{Query: s, Pos: t.pos, Msg: msg}
+		}
+		if t.field == "license" && t.negated && strings.EqualFold(t.value, "unfree") {
+			query.ExcludeUnfree = true
+			continue
+		}
+		if t.field == "version" && isVersionComparison(t.value) {
+			if t.negated {
+				return Query{}, ParseError{Query: s, Pos: t.pos, Msg: "version comparisons can't be excluded, use the opposite comparison instead"}
+			}
+			if _, err := nixversion.ParseConstraint(t.value); err != nil {
+				return Query{}, ParseError{Query: s, Pos: t.valuePos, Msg: err.Error()}
+			}
+			versionRanges = append(versionRanges, t.value)
+			continue
+		}
+		var sub Query
+		if err := set(&sub, t.value); err != nil {
+			return Query{}, ParseError{Query: s, Pos: t.valuePos, Msg: err.Error()}
+		}
+		if t.negated {
+			query.Not = append(query.Not, sub)
+			continue
+		}
+		if _, ok := values[t.field]; !ok {
+			fields = append(fields, t.field)
+		}
+		values[t.field] = append(values[t.field], t.value)
+	}
+
+	if len(search) != 0 {
+		query.Search = &MatchSearch{Search: strings.Join(search, " ")}
+	}
+	if len(versionRanges) != 0 {
+		// Each part has already been checked, so the whole can't fail.
+		constraint, _ := nixversion.ParseConstraint(strings.Join(versionRanges, " "))
+		query.VersionRange = &constraint
+	}
+	// Every value has already been checked, so setting them can't fail.
+	for _, field := range fields {
+		set := queryFields[field]
+		if len(values[field]) == 1 {
+			_ = set(&query, values[field][0])
+			continue
+		}
+		var group Query
+		for _, value := range values[field] {
+			var sub Query
+			_ = set(&sub, value)
+			group.AnyOf = append(group.AnyOf, sub)
+		}
+		query.AllOf = append(query.AllOf, group)
+	}
+	return query, nil
+}
+
+// HasQueryFields reports whether s contains any "field:value" terms, and so
+// should be parsed with [ParseQuery] rather than searched as plain text.
+// Fields that are close to a real field name count too, so that typos are
+// reported instead of silently searched for.
+func HasQueryFields(s string) bool {
+	names := queryFieldNames()
+	for _, word := range strings.Fields(s) {
+		field, _, ok := strings.Cut(strings.TrimPrefix(word, "-"), ":")
+		if !ok || field == "" || strings.IndexFunc(field, func(r rune) bool { return !isLetter(r) }) != -1 {
+			continue
+		}
+		if _, known := queryFields[field]; known || closest(field, names) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// isVersionComparison reports whether a version starts with one of the
+// operators of a [nixversion.Constraint], like ">=14".
+func isVersionComparison(version string) bool {
+	return strings.ContainsAny(version[:1], "<>=!~^")
+}
+
+func queryFieldNames() []string {
+	return slices.Sorted(maps.Keys(queryFields))
+}
+
+// term is a single term of a query.
+type term struct {
+	pos      int
+	valuePos int
+	negated  bool
+	field    string
+	value    string
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) errorf(pos int, format string, args ...any) error {
+	return ParseError{Query: p.input, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// next returns the next term, or nil at the end of the input.
+func (p *parser) next() (*term, error) {
+	for p.pos < len(p.input) && isSpace(p.input[p.pos]) {
+		p.pos++
+	}
+	if p.pos == len(p.input) {
+		return nil, nil
+	}
+	t := &term{pos: p.pos}
+	if p.input[p.pos] == '-' {
+		t.negated = true
+		p.pos++
+	}
+
+	// A field name is a run of letters followed by a colon.
+	start := p.pos
+	end := start
+	for end < len(p.input) && isLetter(rune(p.input[end])) {
+		end++
+	}
+	if end > start && end < len(p.input) && p.input[end] == ':' {
+		t.field = p.input[start:end]
+		p.pos = end + 1
+	}
+
+	t.valuePos = p.pos
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		if t.field != "" {
+			return nil, p.errorf(t.valuePos, "missing value for %q", t.field)
+		}
+		return nil, p.errorf(t.pos, "missing term after %q", "-")
+	}
+	t.value = value
+	return t, nil
+}
+
+// value reads a bare word, or a double-quoted string in which \" and \\ are
+// escapes.
+func (p *parser) value() (string, error) {
+	if p.pos < len(p.input) && p.input[p.pos] == '"' {
+		open := p.pos
+		p.pos++
+		var b strings.Builder
+		for p.pos < len(p.input) {
+			c := p.input[p.pos]
+			switch {
+			case c == '\\' && p.pos+1 < len(p.input):
+				b.WriteByte(p.input[p.pos+1])
+				p.pos += 2
+			case c == '"':
+				p.pos++
+				return b.String(), nil
+			default:
+				b.WriteByte(c)
+				p.pos++
+			}
+		}
+		return "", p.errorf(open, "unterminated quote")
+	}
+	start := p.pos
+	for p.pos < len(p.input) && !isSpace(p.input[p.pos]) {
+		p.pos++
+	}
+	return p.input[start:p.pos], nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+// isLetter reports whether r can be part of a field name.
+func isLetter(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+}
@@ -0,0 +1,99 @@
+package nixsearch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+func TestParseQuery(t *testing.T) {
+	t.Parallel()
+
+	query, err := ParseQuery(`program:rg name:ripgrep* -license:unfree platform:aarch64-darwin fast search`)
+	assert.NoError(t, err)
+	check.Equal(t, Query{
+		Search:        &MatchSearch{Search: "fast search"},
+		Program:       &MatchProgram{Program: "rg"},
+		Name:          &MatchName{Name: "ripgrep"},
+		Platform:      &MatchPlatform{Platform: "aarch64-darwin"},
+		ExcludeUnfree: true,
+	}, query)
+
+	query, err = ParseQuery(`program:python program:python3 -name:python2 maintainer:"Peter \"pd\" Downs"`)
+	assert.NoError(t, err)
+	check.Equal(t, Query{
+		Maintainer: &MatchMaintainer{Maintainer: `Peter "pd" Downs`},
+		AllOf: []Query{{AnyOf: []Query{
+			{Program: &MatchProgram{Program: "python"}},
+			{Program: &MatchProgram{Program: "python3"}},
+		}}},
+		Not: []Query{{Name: &MatchName{Name: "python2"}}},
+	}, query)
+}
+
+func TestParseQueryVersionRange(t *testing.T) {
+	t.Parallel()
+
+	query, err := ParseQuery(`program:rg version:>=14`)
+	assert.NoError(t, err)
+	check.Equal(t, &MatchProgram{Program: "rg"}, query.Program)
+	check.Nil(t, query.Version)
+	assert.NotNil(t, query.VersionRange)
+	check.Equal(t, ">=14", query.VersionRange.String())
+	check.True(t, query.VersionRange.Matches("14.1.0"))
+	check.False(t, query.VersionRange.Matches("13.0.0"))
+
+	// Every comparison has to be satisfied, unlike other repeated fields.
+	query, err = ParseQuery(`program:go version:>=1.20 version:"<1.23"`)
+	assert.NoError(t, err)
+	assert.NotNil(t, query.VersionRange)
+	check.True(t, query.VersionRange.Matches("1.22.5"))
+	check.False(t, query.VersionRange.Matches("1.23.0"))
+	check.Equal(t, 0, len(query.AllOf))
+
+	// Versions without a comparison are still prefixes.
+	query, err = ParseQuery(`program:python version:3.11`)
+	assert.NoError(t, err)
+	check.Equal(t, &MatchVersion{Version: "3.11"}, query.Version)
+	check.Nil(t, query.VersionRange)
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		{`rg progam:rg`, 3, `unknown field "progam", did you mean "program"?`},
+		{`rg colour:red`, 3, `unknown field "colour"`},
+		{`name: rg`, 5, `missing value for "name"`},
+		{`rg - fd`, 3, `missing term after "-"`},
+		{`maintainer:"Peter`, 11, `unterminated quote`},
+		{`rg -version:>=14`, 3, `version comparisons can't be excluded, use the opposite comparison instead`},
+		{`rg version:>=`, 11, `invalid version constraint ">=": ">=" is missing a version`},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParseQuery(tc.query)
+			var parseErr ParseError
+			assert.True(t, errors.As(err, &parseErr))
+			check.Equal(t, tc.pos, parseErr.Pos)
+			check.Equal(t, tc.msg, parseErr.Msg)
+		})
+	}
+}
+
+func TestHasQueryFields(t *testing.T) {
+	t.Parallel()
+
+	check.True(t, HasQueryFields("program:rg"))
+	check.True(t, HasQueryFields("ripgrep -license:unfree"))
+	check.False(t, HasQueryFields("python linter"))
+	check.True(t, HasQueryFields("progam:rg"))
+	check.False(t, HasQueryFields("http://example.com"))
+	check.False(t, HasQueryFields("c++ :"))
+}
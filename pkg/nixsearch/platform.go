@@ -0,0 +1,79 @@
+package nixsearch
+
+import (
+	"encoding/json"
+	"runtime"
+	"slices"
+)
+
+// MatchPlatform filters packages by the systems they are available on, using
+// nix system names like "x86_64-linux" or "aarch64-darwin". Packages that
+// don't list any platforms match too, see [Package.AvailableOn].
+type MatchPlatform struct {
+	Platform string
+}
+
+func (m MatchPlatform) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Dict{
+		"bool": Dict{
+			"should": []Dict{
+				{
+					"term": Dict{
+						"package_platforms": m.Platform,
+					},
+				},
+				{
+					"bool": Dict{
+						"must_not": Dict{
+							"exists": Dict{
+								"field": "package_platforms",
+							},
+						},
+					},
+				},
+			},
+			"minimum_should_match": 1,
+		},
+	})
+}
+
+// CurrentPlatform returns the nix system name of the machine this program is
+// running on, like "aarch64-darwin", or false if there is no equivalent.
+func CurrentPlatform() (string, bool) {
+	return NixSystem(runtime.GOOS, runtime.GOARCH)
+}
+
+// NixSystem translates a Go GOOS and GOARCH pair into the equivalent nix
+// system name, or returns false if there is no equivalent.
+func NixSystem(goos, goarch string) (string, bool) {
+	var cpu string
+	switch goarch {
+	case "amd64":
+		cpu = "x86_64"
+	case "arm64":
+		cpu = "aarch64"
+	case "386":
+		cpu = "i686"
+	case "arm":
+		cpu = "armv7l"
+	case "riscv64":
+		cpu = "riscv64"
+	case "ppc64le":
+		cpu = "powerpc64le"
+	default:
+		return "", false
+	}
+	switch goos {
+	case "linux", "darwin", "freebsd", "netbsd", "openbsd":
+		return cpu + "-" + goos, true
+	default:
+		return "", false
+	}
+}
+
+// AvailableOn reports whether the package can be installed on the given nix
+// system. Packages that don't list any platforms, like most flakes, are
+// assumed to be available everywhere.
+func (p Package) AvailableOn(system string) bool {
+	return len(p.Platforms) == 0 || slices.Contains(p.Platforms, system)
+}
@@ -2,6 +2,9 @@ package nixsearch
 
 import (
 	"encoding/json"
+	"slices"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixversion"
 )
 
 type Query struct {
@@ -10,6 +13,14 @@ type Query struct {
 	Channel    string // which nix-channel index to look at. mutually exclusive with Flakes.
 	Flakes     bool   // if true, uses the flakes index instead. mutually exclusive with Channel.
 
+	// Pagination. From is a simple offset into the results, useful for
+	// jumping to a specific page. SearchAfter is a cursor, the sort values of
+	// the last hit of the previous page (see [Hit.Sort]), and takes precedence
+	// over From when both are set. ElasticSearch refuses offsets past 10,000
+	// results, so walking every result should use SearchAfter.
+	From        int
+	SearchAfter []any
+
 	// Every query can combine multiple different matchers. At least one of
 	// these fields must not be empty for the query to be processed.
 
@@ -19,12 +30,60 @@ type Query struct {
 	Program *MatchProgram
 	// Name filters by the attribute name of the package.
 	Name *MatchName
+	// AttrNames filters by exact attribute names.
+	AttrNames *MatchAttrNames
 	// Version filters by the version of the package.
 	Version *MatchVersion
 	// QueryString filters by a custom ElasticSearch QueryString-syntax query.
 	QueryString *MatchQueryString
+	// License filters by the license of the package.
+	License *MatchLicense
+	// Maintainer filters by the maintainers of the package.
+	Maintainer *MatchMaintainer
+
+	// Platform filters by the systems that the package is available on. It
+	// only narrows down the results of the other matchers, it doesn't
+	// affect their relevance, and it doesn't count towards the query being
+	// non-empty.
+	Platform *MatchPlatform
+	// ExcludeUnfree removes any packages with an unfree license from the
+	// results. Like Platform, it is only a filter.
+	ExcludeUnfree bool
+	// VersionRange filters by a range of versions, like ">=3.11 <3.13". The
+	// index can't compare versions the way that Nix does, so clients don't
+	// send it: it's applied by [SearchVersionRange], which filters results
+	// after fetching them. It is only a filter, and is ignored in nested
+	// queries.
+	VersionRange *nixversion.Constraint
+
+	// Queries can be combined. Only the matchers and filters of nested
+	// queries are used; their Meta and Pagination fields are ignored.
+
+	// AllOf requires every one of the queries to match.
+	AllOf []Query
+	// AnyOf requires at least one of the queries to match.
+	AnyOf []Query
+	// Not excludes anything that matches any of the queries. A query with
+	// only exclusions is considered empty.
+	Not []Query
+}
+
+// Index returns the name of the index that this query searches, without any
+// cluster-specific prefix: "group-manual" for flakes, otherwise
+// "nixos-<channel>".
+func (q Query) Index() string {
+	return indexName(q.Channel, q.Flakes)
 }
 
+func indexName(channel string, flakes bool) string {
+	if flakes {
+		return "group-manual"
+	}
+	return "nixos-" + channel
+}
+
+// ExactlyMatches reports whether the program is exactly one of the values
+// that the query, or any of the queries in AllOf or AnyOf, searches for.
 func (q Query) ExactlyMatches(program string) bool {
 	if q.Program != nil && q.Program.Program == program {
 		return true
@@ -38,70 +97,152 @@ func (q Query) ExactlyMatches(program string) bool {
 	if q.Search != nil && q.Search.Search == program {
 		return true
 	}
+	for _, sub := range slices.Concat(q.AllOf, q.AnyOf) {
+		if sub.ExactlyMatches(program) {
+			return true
+		}
+	}
 	return false
 }
 
-// IsEmpty returns false if any match has been set
+// IsEmpty returns false if any match has been set, directly or in any of the
+// queries in AllOf or AnyOf.
 func (q Query) IsEmpty() bool {
-	if q.Search != nil {
-		return false
-	}
-	if q.Program != nil {
+	if len(q.matchers()) != 0 {
 		return false
 	}
-	if q.Name != nil {
-		return false
-	}
-	if q.QueryString != nil {
-		return false
-	}
-	if q.Version != nil {
-		return false
+	for _, sub := range slices.Concat(q.AllOf, q.AnyOf) {
+		if !sub.IsEmpty() {
+			return false
+		}
 	}
 	return true
 }
 
-// Payload returns a map[string]any that is ready to be serialized to JSON
-// and sent to ElasticSearch.
-func (q Query) Payload() ([]byte, error) {
-	must := []any{
-		Dict{
-			"match": Dict{
-				"type": "package",
-			},
-		},
-	}
+// matchers returns every matcher that has been set on this query.
+func (q Query) matchers() []json.Marshaler {
+	var matchers []json.Marshaler
 	if q.Search != nil {
-		must = append(must, q.Search)
+		matchers = append(matchers, q.Search)
 	}
 	if q.Name != nil {
-		must = append(must, q.Name)
+		matchers = append(matchers, q.Name)
+	}
+	if q.AttrNames != nil {
+		matchers = append(matchers, q.AttrNames)
 	}
 	if q.Program != nil {
-		must = append(must, q.Program)
+		matchers = append(matchers, q.Program)
 	}
 	if q.Version != nil {
-		must = append(must, q.Version)
+		matchers = append(matchers, q.Version)
 	}
 	if q.QueryString != nil {
-		must = append(must, q.QueryString)
+		matchers = append(matchers, q.QueryString)
+	}
+	if q.License != nil {
+		matchers = append(matchers, q.License)
 	}
-	return json.Marshal(Dict{
-		"from": 0,
+	if q.Maintainer != nil {
+		matchers = append(matchers, q.Maintainer)
+	}
+	return matchers
+}
+
+// filters returns every filter that has been set on this query. Filters
+// don't affect the relevance of results.
+func (q Query) filters() []json.Marshaler {
+	var filters []json.Marshaler
+	if q.Platform != nil {
+		filters = append(filters, q.Platform)
+	}
+	return filters
+}
+
+// Payload returns the JSON request body to send to ElasticSearch.
+func (q Query) Payload() ([]byte, error) {
+	return json.Marshal(q.payload())
+}
+
+// payload returns a map[string]any that is ready to be serialized to JSON
+// and sent to ElasticSearch.
+func (q Query) payload() Dict {
+	isPackage := Dict{
+		"match": Dict{
+			"type": "package",
+		},
+	}
+	payload := Dict{
+		"from": q.From,
 		"size": q.MaxResults,
+		// The last key is a tiebreaker. Hits can have the same score, name,
+		// and version, like the same package in two of the indexes that the
+		// prefix matches, and search_after would skip any of them that fell
+		// on a page boundary. It can't be "_id", which can't be sorted on
+		// without enabling fielddata on the cluster.
 		"sort": []Dict{
-			{
-				"_score":            "desc",
-				"package_attr_name": "desc",
-				"package_pversion":  "desc",
-			},
+			{"_score": "desc"},
+			{"package_attr_name": "desc"},
+			{"package_pversion": "desc"},
+			{"_index": "asc"},
 		},
 		"query": Dict{
+			"bool": q.boolQuery(isPackage),
+		},
+		"highlight": highlight(),
+	}
+	if len(q.SearchAfter) != 0 {
+		payload["from"] = 0
+		payload["search_after"] = q.SearchAfter
+	}
+	return payload
+}
+
+// boolQuery compiles the query's matchers, filters, and nested queries into
+// the body of an ElasticSearch bool query, after any given must clauses.
+func (q Query) boolQuery(must ...any) Dict {
+	for _, m := range q.matchers() {
+		must = append(must, m)
+	}
+	for _, sub := range q.AllOf {
+		must = append(must, sub.clause())
+	}
+	if len(q.AnyOf) != 0 {
+		should := make([]any, 0, len(q.AnyOf))
+		for _, sub := range q.AnyOf {
+			should = append(should, sub.clause())
+		}
+		must = append(must, Dict{
 			"bool": Dict{
-				"must": must,
+				"should":               should,
+				"minimum_should_match": 1,
 			},
-		},
-	})
+		})
+	}
+	var mustNot []any
+	if q.ExcludeUnfree {
+		mustNot = append(mustNot, unfreeClauses()...)
+	}
+	for _, sub := range q.Not {
+		mustNot = append(mustNot, sub.clause())
+	}
+
+	boolQuery := Dict{}
+	if len(must) != 0 {
+		boolQuery["must"] = must
+	}
+	if filters := q.filters(); len(filters) != 0 {
+		boolQuery["filter"] = filters
+	}
+	if len(mustNot) != 0 {
+		boolQuery["must_not"] = mustNot
+	}
+	return boolQuery
+}
+
+// clause returns the query as a bool query, to nest inside of another.
+func (q Query) clause() Dict {
+	return Dict{"bool": q.boolQuery()}
 }
 
 // Dict is a convenience helper for constructing JSON queries to send to Elasticsearch.
@@ -0,0 +1,193 @@
+package nixsearch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+func decodePayload(t *testing.T, query Query) Dict {
+	t.Helper()
+	b, err := query.Payload()
+	assert.NoError(t, err)
+	var payload Dict
+	assert.NoError(t, json.Unmarshal(b, &payload))
+	return payload
+}
+
+func TestPayloadPagination(t *testing.T) {
+	t.Parallel()
+
+	query := Query{
+		MaxResults: 20,
+		Name:       &MatchName{Name: "python3Packages."},
+	}
+	payload := decodePayload(t, query)
+	check.Equal(t, 0.0, payload["from"])
+	check.Equal(t, 20.0, payload["size"])
+	_, ok := payload["search_after"]
+	check.False(t, ok)
+
+	query.From = 40
+	payload = decodePayload(t, query)
+	check.Equal(t, 40.0, payload["from"])
+
+	// The cursor takes precedence over the offset.
+	query.SearchAfter = []any{12.5, "python3Packages.requests", "2.31.0", "latest-42-nixos-unstable"}
+	payload = decodePayload(t, query)
+	check.Equal(t, 0.0, payload["from"])
+	check.Equal[any](t, []any{12.5, "python3Packages.requests", "2.31.0", "latest-42-nixos-unstable"}, payload["search_after"])
+}
+
+func TestPayloadSortOrder(t *testing.T) {
+	t.Parallel()
+
+	// search_after values are positional, so the sort keys must be sent as
+	// an ordered list rather than a single object.
+	payload := decodePayload(t, Query{MaxResults: 1})
+	check.Equal[any](t, []any{
+		map[string]any{"_score": "desc"},
+		map[string]any{"package_attr_name": "desc"},
+		map[string]any{"package_pversion": "desc"},
+		map[string]any{"_index": "asc"},
+	}, payload["sort"])
+}
+
+func TestPayloadPlatformFilter(t *testing.T) {
+	t.Parallel()
+
+	// The platform is a filter, so that it doesn't change the relevance of
+	// the results, and isn't enough to make a query on its own.
+	query := Query{Platform: &MatchPlatform{Platform: "aarch64-darwin"}}
+	check.True(t, query.IsEmpty())
+
+	query.Name = &MatchName{Name: "ripgrep"}
+	payload := decodePayload(t, query)
+	// Packages that don't list any platforms, like most flakes, are assumed
+	// to be available everywhere.
+	check.Equal[any](t, []any{
+		map[string]any{"bool": map[string]any{
+			"should": []any{
+				map[string]any{"term": map[string]any{"package_platforms": "aarch64-darwin"}},
+				map[string]any{"bool": map[string]any{
+					"must_not": map[string]any{"exists": map[string]any{"field": "package_platforms"}},
+				}},
+			},
+			"minimum_should_match": 1.0,
+		}},
+	}, payload["query"].(map[string]any)["bool"].(map[string]any)["filter"])
+}
+
+func TestNixSystem(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct{ goos, goarch, system string }{
+		{"linux", "amd64", "x86_64-linux"},
+		{"linux", "arm64", "aarch64-linux"},
+		{"darwin", "arm64", "aarch64-darwin"},
+		{"darwin", "amd64", "x86_64-darwin"},
+	} {
+		system, ok := NixSystem(tc.goos, tc.goarch)
+		check.True(t, ok)
+		check.Equal(t, tc.system, system)
+	}
+	_, ok := NixSystem("windows", "amd64")
+	check.False(t, ok)
+}
+
+func TestPayloadExcludeUnfree(t *testing.T) {
+	t.Parallel()
+
+	query := Query{Name: &MatchName{Name: "terraform"}}
+	payload := decodePayload(t, query)
+	_, ok := payload["query"].(map[string]any)["bool"].(map[string]any)["must_not"]
+	check.False(t, ok)
+
+	query.ExcludeUnfree = true
+	payload = decodePayload(t, query)
+	mustNot := payload["query"].(map[string]any)["bool"].(map[string]any)["must_not"]
+	check.Equal[any](t, map[string]any{
+		"prefix": map[string]any{"package_license_set": "Unfree"},
+	}, mustNot.([]any)[0])
+}
+
+func TestLicense(t *testing.T) {
+	t.Parallel()
+
+	mit := License{FullName: "MIT License", URL: "https://spdx.org/licenses/MIT.html"}
+	check.Equal(t, "MIT", mit.SPDXID())
+	check.False(t, mit.IsUnfree())
+	check.True(t, License{FullName: "Unfree redistributable"}.IsUnfree())
+	check.True(t, Package{Licenses: []License{mit, {FullName: "Elastic License 2.0"}}}.IsUnfree())
+	check.True(t, License{FullName: "Functional Source License, Version 1.1, MIT Future License"}.IsUnfree())
+}
+
+func TestPayloadLicense(t *testing.T) {
+	t.Parallel()
+
+	// SPDX ids are translated to the full names that the index stores, in
+	// any case, and only match that license exactly.
+	payload := decodePayload(t, Query{License: &MatchLicense{License: "mit"}})
+	must := payload["query"].(map[string]any)["bool"].(map[string]any)["must"].([]any)
+	check.Equal[any](t, map[string]any{"term": map[string]any{"package_license_set": "MIT License"}}, must[1])
+	payload = decodePayload(t, Query{License: &MatchLicense{License: "GPL-3.0-only"}})
+	must = payload["query"].(map[string]any)["bool"].(map[string]any)["must"].([]any)
+	check.Equal[any](t, map[string]any{"term": map[string]any{"package_license_set": "GNU General Public License v3.0 only"}}, must[1])
+
+	// Anything else is matched by name, or part of it.
+	payload = decodePayload(t, Query{License: &MatchLicense{License: "GNU General"}})
+	must = payload["query"].(map[string]any)["bool"].(map[string]any)["must"].([]any)
+	check.Equal[any](t, map[string]any{"dis_max": map[string]any{
+		"tie_breaker": 0.7,
+		"queries": []any{
+			map[string]any{"term": map[string]any{"package_license_set": "GNU General"}},
+			map[string]any{"wildcard": map[string]any{"package_license_set": map[string]any{
+				"value":            "*GNU General*",
+				"case_insensitive": true,
+			}}},
+		},
+	}}, must[1])
+}
+
+func TestPayloadComposition(t *testing.T) {
+	t.Parallel()
+
+	// Only exclusions isn't enough to make a query.
+	query := Query{Not: []Query{{Name: &MatchName{Name: "python2"}}}}
+	check.True(t, query.IsEmpty())
+
+	query.AnyOf = []Query{
+		{Program: &MatchProgram{Program: "python"}},
+		{Program: &MatchProgram{Program: "python3"}},
+	}
+	check.False(t, query.IsEmpty())
+	check.True(t, query.ExactlyMatches("python3"))
+	check.False(t, query.ExactlyMatches("python2"))
+
+	name, err := json.Marshal(MatchName{Name: "python2"})
+	assert.NoError(t, err)
+	python, err := json.Marshal(MatchProgram{Program: "python"})
+	assert.NoError(t, err)
+	python3, err := json.Marshal(MatchProgram{Program: "python3"})
+	assert.NoError(t, err)
+	var want map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"must": [
+			{"match": {"type": "package"}},
+			{"bool": {
+				"should": [
+					{"bool": {"must": [`+string(python)+`]}},
+					{"bool": {"must": [`+string(python3)+`]}}
+				],
+				"minimum_should_match": 1
+			}}
+		],
+		"must_not": [
+			{"bool": {"must": [`+string(name)+`]}}
+		]
+	}`), &want))
+	payload := decodePayload(t, query)
+	check.Equal[any](t, want, payload["query"].(map[string]any)["bool"])
+}
@@ -0,0 +1,143 @@
+package nixsearch
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// queryStringAliases are the short field names that can be used in a
+// [MatchQueryString] query instead of the real names of the index fields.
+var queryStringAliases = map[string]string{ //nolint:gochecknoglobals
+	"program":    "package_programs",
+	"attr":       "package_attr_name",
+	"pname":      "package_pname",
+	"desc":       "package_description",
+	"version":    "package_pversion",
+	"license":    "package_license_set",
+	"maintainer": "package_maintainers_set",
+	"platform":   "package_platforms",
+}
+
+// queryStringFields returns the name of every field that can be used in a
+// [MatchQueryString] query: every field of [Package], including the fields
+// of nested objects like "package_license.fullName", and the fields that are
+// only used for searching and aren't decoded.
+func queryStringFields() []string {
+	fields := []string{"package_license_set", "package_longDescription"}
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := range t.NumField() {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			fields = append(fields, prefix+name)
+			ft := t.Field(i).Type
+			if ft.Kind() == reflect.Slice {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				walk(ft, prefix+name+".")
+			}
+		}
+	}
+	walk(reflect.TypeOf(Package{}), "")
+	slices.Sort(fields)
+	return slices.Compact(fields)
+}
+
+// RewriteQueryString checks a query written in ElasticSearch's QueryString
+// syntax before it is sent, and rewrites any short field aliases, like
+// "program:rg", to the names of the real fields, like "package_programs:rg".
+// Unknown fields, unbalanced parentheses, and unterminated quotes are
+// reported as a [ParseError].
+//
+// [MatchQueryString] does this automatically; it is exported so that queries
+// can be checked before searching.
+func RewriteQueryString(s string) (string, error) {
+	known := queryStringFields()
+	var out strings.Builder
+	var parens []int
+	termStart := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			out.WriteByte(c)
+			if i+1 < len(s) {
+				i++
+				out.WriteByte(s[i])
+			}
+			termStart = false
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return "", ParseError{Query: s, Pos: i, Msg: "unterminated quote"}
+			}
+			out.WriteString(s[i : end+1])
+			i = end
+			termStart = false
+		case c == '(':
+			parens = append(parens, i)
+			out.WriteByte(c)
+			termStart = true
+		case c == ')':
+			if len(parens) == 0 {
+				return "", ParseError{Query: s, Pos: i, Msg: "unmatched closing parenthesis"}
+			}
+			parens = parens[:len(parens)-1]
+			out.WriteByte(c)
+			termStart = false
+		case c == ' ' || c == '\t' || c == '\n' || c == '+' || c == '-' || c == '!':
+			out.WriteByte(c)
+			termStart = true
+		case termStart:
+			end := i
+			for end < len(s) && isFieldByte(s[end]) {
+				end++
+			}
+			if end < len(s) && end > i && s[end] == ':' {
+				field := s[i:end]
+				if alias, ok := queryStringAliases[field]; ok {
+					field = alias
+				} else if field != "_exists_" && !strings.Contains(field, "*") && !slices.Contains(known, field) {
+					return "", unknownQueryStringField(s, i, field, known)
+				}
+				out.WriteString(field)
+				i = end - 1
+			} else {
+				out.WriteByte(c)
+			}
+			termStart = false
+		default:
+			out.WriteByte(c)
+		}
+	}
+	if len(parens) != 0 {
+		return "", ParseError{Query: s, Pos: parens[len(parens)-1], Msg: "unclosed parenthesis"}
+	}
+	return out.String(), nil
+}
+
+func isFieldByte(c byte) bool {
+	return isLetter(rune(c)) || '0' <= c && c <= '9' || c == '_' || c == '.' || c == '*'
+}
+
+func unknownQueryStringField(s string, pos int, field string, known []string) error {
+	aliases := slices.Sorted(maps.Keys(queryStringAliases))
+	msg := fmt.Sprintf("unknown field %q", field)
+	if suggestion := closest(field, slices.Concat(known, aliases)); suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", suggestion)
+	}
+	msg += fmt.Sprintf(" (aliases: %s; fields: %s)", strings.Join(aliases, ", "), strings.Join(known, ", "))
+	return ParseError{Query: s, Pos: pos, Msg: msg}
+}
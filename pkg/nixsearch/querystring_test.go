@@ -0,0 +1,58 @@
+package nixsearch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+func TestRewriteQueryString(t *testing.T) {
+	t.Parallel()
+
+	for query, want := range map[string]string{
+		"package_programs:(crystal OR irb)":                      "package_programs:(crystal OR irb)",
+		"package_description:(MIT Scheme)":                       "package_description:(MIT Scheme)",
+		`program:rg AND -desc:"search (fast"`:                    `package_programs:rg AND -package_description:"search (fast"`,
+		"(attr:python3* OR pname:python) !license:Unfree":        "(package_attr_name:python3* OR package_pname:python) !package_license_set:Unfree",
+		"package_license.fullName:MIT _exists_:package_programs": "package_license.fullName:MIT _exists_:package_programs",
+		`package_homepage:https\://example.com`:                  `package_homepage:https\://example.com`,
+		"ripgrep fast":                                           "ripgrep fast",
+	} {
+		got, err := RewriteQueryString(query)
+		check.NoError(t, err)
+		check.Equal(t, want, got)
+	}
+}
+
+func TestRewriteQueryStringErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		{"package_program:foo", 0, `unknown field "package_program", did you mean "package_programs"?`},
+		{"rg AND descr:fast", 7, `unknown field "descr", did you mean "desc"?`},
+		{"(a OR (b AND c)", 0, "unclosed parenthesis"},
+		{"a OR b)", 6, "unmatched closing parenthesis"},
+		{`desc:"fast search`, 5, "unterminated quote"},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			t.Parallel()
+			_, err := RewriteQueryString(tc.query)
+			var parseErr ParseError
+			assert.True(t, errors.As(err, &parseErr))
+			check.Equal(t, tc.pos, parseErr.Pos)
+			check.True(t, strings.HasPrefix(parseErr.Msg, tc.msg))
+		})
+	}
+
+	// The query is checked when it is sent, too.
+	_, err := Query{QueryString: &MatchQueryString{QueryString: "package_program:foo"}}.Payload()
+	var parseErr ParseError
+	check.True(t, errors.As(err, &parseErr))
+}
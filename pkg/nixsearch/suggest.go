@@ -0,0 +1,41 @@
+package nixsearch
+
+// closest returns whichever candidate is the fewest edits away from s, as
+// long as it's close enough to plausibly be what was meant; otherwise it
+// returns "".
+func closest(s string, candidates []string) string {
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		d := levenshtein(s, candidate)
+		if bestDistance == -1 || d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	if bestDistance == -1 || bestDistance > max(2, len([]rune(s))/3) {
+		return ""
+	}
+	return best
+}
+
+// levenshtein returns the number of single-character insertions, deletions,
+// or substitutions needed to turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
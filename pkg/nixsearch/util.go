@@ -0,0 +1,15 @@
+package nixsearch
+
+import "strings"
+
+// firstOf returns the first non-empty string from a slice of strings, stripped
+// of all whitespace.
+func firstOf(s ...string) string {
+	for _, x := range s {
+		x = strings.TrimSpace(x)
+		if x != "" {
+			return x
+		}
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package nixsearch
+
+import "context"
+
+const (
+	// versionRangeOverfetch is how many times more results than requested
+	// [SearchVersionRange] fetches at once, since many of them won't be in
+	// the range.
+	versionRangeOverfetch = 5
+	// versionRangeMinPageSize is the fewest results that [SearchVersionRange]
+	// fetches at once.
+	versionRangeMinPageSize = 100
+	// maxResultWindow is the furthest that ElasticSearch will page into the
+	// results of a search with an offset.
+	maxResultWindow = 10_000
+)
+
+// SearchVersionRange is like [Client.SearchWithMeta], but only returns
+// packages whose version is in query.VersionRange, if it's set. The index
+// can't compare versions the way that Nix does, so packages are filtered
+// after they've been fetched: pages of results several times larger than
+// query.MaxResults are fetched until there are enough packages in the range,
+// or there are no more results. query.From skips packages in the range, not
+// results.
+//
+// The Total of the result only counts packages in the range that were seen,
+// so unless every result was fetched, it's a lower bound.
+func SearchVersionRange(ctx context.Context, client Client, query Query) (SearchResult, error) {
+	if query.VersionRange == nil {
+		return client.SearchWithMeta(ctx, query)
+	}
+	constraint := *query.VersionRange
+	want := query.From + query.MaxResults
+	page := query
+	page.From = 0
+	page.SearchAfter = nil
+	page.MaxResults = max(query.MaxResults*versionRangeOverfetch, versionRangeMinPageSize)
+
+	var matched []PackageHit
+	var result SearchResult
+	for {
+		pageResult, err := client.SearchWithMeta(ctx, page)
+		if err != nil {
+			return SearchResult{}, err
+		}
+		result.Stale = result.Stale || pageResult.Stale
+		for _, hit := range pageResult.Hits {
+			if constraint.Matches(hit.Version) {
+				matched = append(matched, hit)
+			}
+		}
+		page.From += len(pageResult.Hits)
+		exhausted := len(pageResult.Hits) < page.MaxResults || page.From >= pageResult.Total && !pageResult.TotalIsLowerBound
+		if exhausted {
+			break
+		}
+		if len(matched) >= want || page.From+page.MaxResults > maxResultWindow {
+			result.TotalIsLowerBound = true
+			break
+		}
+	}
+	result.Total = len(matched)
+	if query.From < len(matched) {
+		result.Hits = matched[query.From:min(want, len(matched))]
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package nixsearch
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixversion"
+)
+
+func TestSearchVersionRange(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// 250 packages, versions 1.0 through 1.249, so that the 50 in the range
+	// are spread over several pages of results.
+	client, err := NewLocalClient(t.TempDir())
+	assert.NoError(t, err)
+	var packages []Package
+	for i := range 250 {
+		packages = append(packages, Package{
+			Type:     "package",
+			AttrName: fmt.Sprintf("pkg%d", i),
+			Name:     "pkg",
+			Version:  fmt.Sprintf("1.%d", i),
+		})
+	}
+	_, err = client.Mirror.Write("nixos-unstable", packageSeq(packages...))
+	assert.NoError(t, err)
+	constraint, err := nixversion.ParseConstraint(">=1.200")
+	assert.NoError(t, err)
+	inRange := func(hits []PackageHit) bool {
+		for _, hit := range hits {
+			if !constraint.Matches(hit.Version) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Asking for more than there are fetches every result, so the total is
+	// exact.
+	query := Query{Channel: "unstable", MaxResults: 100, Name: &MatchName{Name: "pkg"}, VersionRange: &constraint}
+	result, err := SearchVersionRange(ctx, client, query)
+	assert.NoError(t, err)
+	check.Equal(t, 50, len(result.Hits))
+	check.Equal(t, 50, result.Total)
+	check.False(t, result.TotalIsLowerBound)
+	check.True(t, inRange(result.Hits))
+
+	// Asking for fewer stops early.
+	query.MaxResults = 5
+	result, err = SearchVersionRange(ctx, client, query)
+	assert.NoError(t, err)
+	check.Equal(t, 5, len(result.Hits))
+	check.True(t, result.TotalIsLowerBound)
+	check.True(t, inRange(result.Hits))
+
+	// From counts packages in the range.
+	query.From = 48
+	result, err = SearchVersionRange(ctx, client, query)
+	assert.NoError(t, err)
+	check.Equal(t, 2, len(result.Hits))
+	check.Equal(t, 50, result.Total)
+	check.True(t, inRange(result.Hits))
+
+	// The query language sets the same range.
+	query, err = ParseQuery("name:pkg version:>=1.200")
+	assert.NoError(t, err)
+	query.Channel, query.MaxResults = "unstable", 100
+	result, err = SearchVersionRange(ctx, client, query)
+	assert.NoError(t, err)
+	check.Equal(t, 50, len(result.Hits))
+	check.True(t, inRange(result.Hits))
+
+	// Without a range, every result is returned.
+	query.VersionRange = nil
+	result, err = SearchVersionRange(ctx, client, query)
+	assert.NoError(t, err)
+	check.Equal(t, 100, len(result.Hits))
+}
@@ -0,0 +1,163 @@
+package nixversion
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Constraint is a range of versions, like ">=3.11 <3.13". Every one of its
+// terms must be satisfied. Versions are compared with [Compare].
+type Constraint struct {
+	raw   string
+	terms []bound
+}
+
+// bound is a single comparison, like ">=3.11".
+type bound struct {
+	op      string
+	version string
+}
+
+// operators are the comparisons that a bound can use. Longer operators come
+// first, so that ">=" isn't read as ">" followed by "=3.11".
+var operators = []string{">=", "<=", "==", "!=", ">", "<", "=", "~", "^"} //nolint:gochecknoglobals
+
+// ParseConstraint parses a version constraint made of one or more terms,
+// separated by spaces or commas. Each term is a version with one of these
+// operators in front of it:
+//
+//   - ">=", ">", "<=", "<": newer or older than the version;
+//   - "=" or "==", or no operator: exactly the version;
+//   - "!=": anything but the version;
+//   - "~": the version, or anything newer with the same major and minor
+//     version, so "~1.20" means ">=1.20 <1.21" and "~1" means ">=1 <2";
+//   - "^": the version, or anything newer up to the next change of its first
+//     non-zero component, so "^2" means ">=2 <3" and "^0.3" means
+//     ">=0.3 <0.4".
+//
+// The upper bounds of "~" and "^" exclude pre-releases of the next version,
+// like "1.21pre1" and "1.21-rc1".
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: s}
+	words := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == '\t' || r == ',' })
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		op := ""
+		for _, candidate := range operators {
+			if strings.HasPrefix(word, candidate) {
+				op = candidate
+				break
+			}
+		}
+		version := strings.TrimPrefix(word, op)
+		// Allow a space between the operator and the version, like ">= 3.11".
+		if version == "" && op != "" && i+1 < len(words) {
+			i++
+			version = words[i]
+		}
+		if version == "" {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: %q is missing a version", s, op)
+		}
+		terms, err := expand(op, version)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: %w", s, err)
+		}
+		c.terms = append(c.terms, terms...)
+	}
+	if len(c.terms) == 0 {
+		return Constraint{}, fmt.Errorf("invalid version constraint %q: it's empty", s)
+	}
+	return c, nil
+}
+
+// expand turns a term into the bounds that it stands for.
+func expand(op, version string) ([]bound, error) {
+	switch op {
+	case "", "==":
+		return []bound{{"=", version}}, nil
+	case "~", "^":
+		numbers := leadingNumbers(version)
+		if len(numbers) == 0 {
+			return nil, fmt.Errorf("%q needs a version that starts with a number, like %s1.20", op+version, op)
+		}
+		// The component that changes in the next version that's out of
+		// range: the minor version for "~", unless there's only a major
+		// version, and the first non-zero component for "^".
+		bump := 0
+		if op == "~" {
+			bump = min(1, len(numbers)-1)
+		} else {
+			for bump < len(numbers)-1 && numbers[bump] == 0 {
+				bump++
+			}
+		}
+		next := make([]string, 0, bump+1)
+		for _, n := range numbers[:bump] {
+			next = append(next, strconv.Itoa(n))
+		}
+		next = append(next, strconv.Itoa(numbers[bump]+1))
+		// "pre" sorts before any other suffix, so this also excludes the
+		// pre-releases of the next version.
+		upper := strings.Join(next, ".") + "pre"
+		return []bound{{">=", version}, {"<", upper}}, nil
+	default:
+		return []bound{{op, version}}, nil
+	}
+}
+
+// leadingNumbers returns the numeric components at the start of a version:
+// [1 20] for "1.20rc1".
+func leadingNumbers(version string) []int {
+	var numbers []int
+	for _, c := range Split(version) {
+		n, err := strconv.Atoi(c)
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+// Matches reports whether the version satisfies every term of the
+// constraint.
+func (c Constraint) Matches(version string) bool {
+	for _, b := range c.terms {
+		cmp := Compare(version, b.version)
+		var ok bool
+		switch b.op {
+		case ">=":
+			ok = cmp >= 0
+		case ">":
+			ok = cmp > 0
+		case "<=":
+			ok = cmp <= 0
+		case "<":
+			ok = cmp < 0
+		case "!=":
+			ok = cmp != 0
+		default:
+			ok = cmp == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// And returns a constraint that is only satisfied by versions that satisfy
+// both c and other.
+func (c Constraint) And(other Constraint) Constraint {
+	return Constraint{
+		raw:   c.raw + " " + other.raw,
+		terms: append(slices.Clip(c.terms), other.terms...),
+	}
+}
+
+// String returns the constraint as it was written.
+func (c Constraint) String() string {
+	return c.raw
+}
@@ -0,0 +1,66 @@
+package nixversion
+
+import (
+	"testing"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+)
+
+func TestConstraint(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		constraint string
+		matches    []string
+		rejects    []string
+	}{
+		{">=3.11 <3.13", []string{"3.11", "3.11.9", "3.12.4"}, []string{"3.10.14", "3.13", "3.13.0"}},
+		{">= 3.11, < 3.13", []string{"3.12"}, []string{"3.13"}},
+		{"~1.20", []string{"1.20", "1.20.14"}, []string{"1.19.9", "1.21", "1.21pre1", "1.21-rc1", "2.0"}},
+		{"~1", []string{"1.0", "1.99"}, []string{"0.9", "2"}},
+		{"^2", []string{"2", "2.0.1", "2.99"}, []string{"1.9", "3.0", "3.0pre"}},
+		{"^0.3", []string{"0.3.1"}, []string{"0.2", "0.4"}},
+		{"1.2.3", []string{"1.2.3", "1-2-3"}, []string{"1.2.4", "1.2"}},
+		{"!=2.0 >1", []string{"1.5", "2.1"}, []string{"1", "2.0"}},
+		{">2.0pre1", []string{"2.0pre2", "2.0"}, []string{"2.0pre1", "1.9"}},
+	} {
+		t.Run(tc.constraint, func(t *testing.T) {
+			t.Parallel()
+			c, err := ParseConstraint(tc.constraint)
+			assert.NoError(t, err)
+			check.Equal(t, tc.constraint, c.String())
+			for _, version := range tc.matches {
+				check.True(t, c.Matches(version))
+			}
+			for _, version := range tc.rejects {
+				check.False(t, c.Matches(version))
+			}
+		})
+	}
+}
+
+func TestConstraintAnd(t *testing.T) {
+	t.Parallel()
+
+	lower, err := ParseConstraint(">=3.11")
+	assert.NoError(t, err)
+	upper, err := ParseConstraint("<3.13")
+	assert.NoError(t, err)
+	both := lower.And(upper)
+	check.Equal(t, ">=3.11 <3.13", both.String())
+	check.True(t, both.Matches("3.12"))
+	check.False(t, both.Matches("3.13"))
+	check.False(t, both.Matches("3.10"))
+	// Neither side is changed.
+	check.True(t, lower.Matches("3.13"))
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"", " , ", ">=", "~beta", "^"} {
+		_, err := ParseConstraint(s)
+		check.Error(t, err)
+	}
+}
@@ -0,0 +1,102 @@
+// Package nixversion compares package versions the same way that Nix does, so
+// that "1.10" sorts after "1.9" and "2.0pre1" sorts before "2.0".
+package nixversion
+
+import (
+	"strconv"
+)
+
+// Split splits a version into its components, like Nix's
+// builtins.splitVersion: "1.2.3-rc1" becomes ["1", "2", "3", "rc", "1"].
+// Components are separated by dots and dashes, and by any change between
+// digits and other characters.
+func Split(version string) []string {
+	var components []string
+	for version != "" {
+		var c string
+		c, version = next(version)
+		if c == "" {
+			break
+		}
+		components = append(components, c)
+	}
+	return components
+}
+
+// Compare compares two versions like Nix's builtins.compareVersions,
+// returning -1 if a is older than b, 1 if a is newer than b, and 0 if they
+// are the same. Versions are compared component by component (see [Split]):
+//
+//   - numbers are compared numerically;
+//   - "pre" is older than anything else, so "2.0pre1" < "2.0";
+//   - a missing component is older than a number, so "1.0" < "1.0.1";
+//   - words are older than numbers, so "2.3a" < "2.3.1";
+//   - otherwise words are compared lexically.
+func Compare(a, b string) int {
+	for a != "" || b != "" {
+		var ca, cb string
+		ca, a = next(a)
+		cb, b = next(b)
+		switch {
+		case componentLess(ca, cb):
+			return -1
+		case componentLess(cb, ca):
+			return 1
+		}
+	}
+	return 0
+}
+
+// next returns the first component of a version, and the rest of the version
+// after it.
+func next(version string) (string, string) {
+	i := 0
+	for i < len(version) && isSeparator(version[i]) {
+		i++
+	}
+	start := i
+	if i < len(version) && isDigit(version[i]) {
+		for i < len(version) && isDigit(version[i]) {
+			i++
+		}
+	} else {
+		for i < len(version) && !isDigit(version[i]) && !isSeparator(version[i]) {
+			i++
+		}
+	}
+	return version[start:i], version[i:]
+}
+
+func componentLess(a, b string) bool {
+	na, aIsNumber := number(a)
+	nb, bIsNumber := number(b)
+	switch {
+	case aIsNumber && bIsNumber:
+		return na < nb
+	case a == "" && bIsNumber:
+		return true
+	case a == "pre" && b != "pre":
+		return true
+	case b == "pre":
+		return false
+	case bIsNumber:
+		return true
+	case aIsNumber:
+		return false
+	default:
+		return a < b
+	}
+}
+
+func number(c string) (int, bool) {
+	n, err := strconv.Atoi(c)
+	return n, err == nil
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isSeparator(c byte) bool {
+	return c == '.' || c == '-'
+}
@@ -0,0 +1,53 @@
+package nixversion
+
+import (
+	"testing"
+
+	"github.com/peterldowns/testy/check"
+)
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+
+	check.Equal(t, nil, Split(""))
+	check.Equal(t, []string{"1", "2", "3"}, Split("1.2.3"))
+	check.Equal(t, []string{"2", "0", "pre", "1"}, Split("2.0pre1"))
+	check.Equal(t, []string{"1", "0", "rc", "1"}, Split("1.0-rc.1"))
+	check.Equal(t, []string{"unstable", "2024", "01", "15"}, Split("unstable-2024-01-15"))
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	// Each version is older than the ones after it. These are the examples
+	// from the tests of Nix's compareVersions.
+	ordered := []string{
+		"1.0pre1",
+		"1.0",
+		"1.0a",
+		"1.0b",
+		"1.0.1",
+		"1.1",
+		"1.2pre",
+		"1.2",
+		"1.2.0",
+		"1.9",
+		"1.10",
+		"2.0pre1",
+		"2.0pre2",
+		"2.0",
+		"2.3a",
+		"2.3.1",
+	}
+	for i, a := range ordered {
+		check.Equal(t, 0, Compare(a, a))
+		for _, b := range ordered[i+1:] {
+			check.Equal(t, -1, Compare(a, b))
+			check.Equal(t, 1, Compare(b, a))
+		}
+	}
+
+	// Separators don't matter.
+	check.Equal(t, 0, Compare("1.2.3", "1-2-3"))
+	check.Equal(t, 0, Compare("01", "1"))
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+)
+
+// Package is how a package is printed as JSON: every field of the package,
+// its score and index, plus the channel it was found in, after resolving
+// symbolic names like "stable".
+type Package struct {
+	nixsearch.PackageHit
+	Channel string `json:"channel,omitempty"`
+}
+
+// Option is the equivalent of Package for options.
+type Option struct {
+	nixsearch.Option
+	Channel string `json:"channel,omitempty"`
+}
+
+// jsonLines prints each result as JSON, on its own line.
+type jsonLines struct{ Options }
+
+func (r jsonLines) Render(w io.Writer, result nixsearch.SearchResult) error {
+	enc := json.NewEncoder(w)
+	for _, hit := range result.Hits {
+		// { ... pkg contents ... }
+		if err := enc.Encode(Package{PackageHit: hit, Channel: r.Channel}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r jsonLines) RenderOptions(w io.Writer, options []nixsearch.Option) error {
+	enc := json.NewEncoder(w)
+	for _, option := range options {
+		// { ... option contents ... }
+		if err := enc.Encode(Option{Option: option, Channel: r.Channel}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonArray prints every result as a single JSON array.
+type jsonArray struct{ Options }
+
+func (r jsonArray) Render(w io.Writer, result nixsearch.SearchResult) error {
+	return json.NewEncoder(w).Encode(r.packages(result.Hits))
+}
+
+// packages returns the hits as they're printed as JSON, which is never null.
+func (o Options) packages(hits []nixsearch.PackageHit) []Package {
+	packages := make([]Package, 0, len(hits))
+	for _, hit := range hits {
+		packages = append(packages, Package{PackageHit: hit, Channel: o.Channel})
+	}
+	return packages
+}
+
+// Envelope is the single JSON document printed by the "json-envelope"
+// format: the results, along with what was searched and how many results
+// there are in total. Every field is always present, so that a search
+// without any results can be told apart from one that failed.
+type Envelope struct {
+	Query EnvelopeQuery `json:"query"`
+	// Channel is the channel that was searched, after resolving symbolic
+	// names like "stable", or "" for flakes.
+	Channel string `json:"channel"`
+	// Index is the index that the results came from, or the name of the
+	// index that was searched if there weren't any.
+	Index string `json:"index"`
+	// Total is how many packages matched, across every page.
+	Total int `json:"total"`
+	// TotalIsLowerBound is set when there are at least Total matches.
+	TotalIsLowerBound bool `json:"total_is_lower_bound"`
+	// Stale is set when the results are an expired cache entry, returned
+	// because the search itself failed.
+	Stale bool `json:"stale"`
+	// TookMS is how long the search took, in milliseconds.
+	TookMS int64 `json:"took_ms"`
+	// Results are the packages on this page of the results.
+	Results []Package `json:"results"`
+}
+
+// EnvelopeQuery describes the query that found the results in an Envelope.
+type EnvelopeQuery struct {
+	Flakes     bool `json:"flakes"`
+	From       int  `json:"from"`
+	MaxResults int  `json:"max_results"`
+	// Request is the body of the request that was sent to ElasticSearch,
+	// or null if the results took more than one request, because every
+	// page was fetched or the results were filtered by a version range.
+	Request json.RawMessage `json:"request"`
+}
+
+// jsonEnvelope prints the results as an Envelope.
+type jsonEnvelope struct{ Options }
+
+func (r jsonEnvelope) Render(w io.Writer, result nixsearch.SearchResult) error {
+	var request json.RawMessage
+	if !r.AllPages && r.Query.VersionRange == nil {
+		payload, err := r.Query.Payload()
+		if err != nil {
+			return err
+		}
+		request = payload
+	}
+	index := r.Query.Index()
+	if len(result.Hits) != 0 && result.Hits[0].Index != "" {
+		index = result.Hits[0].Index
+	}
+	return json.NewEncoder(w).Encode(Envelope{
+		Query: EnvelopeQuery{
+			Flakes:     r.Query.Flakes,
+			From:       r.Query.From,
+			MaxResults: r.Query.MaxResults,
+			Request:    request,
+		},
+		Channel:           r.Channel,
+		Index:             index,
+		Total:             result.Total,
+		TotalIsLowerBound: result.TotalIsLowerBound,
+		Stale:             result.Stale,
+		TookMS:            r.Took.Milliseconds(),
+		Results:           r.packages(result.Hits),
+	})
+}
@@ -0,0 +1,237 @@
+// Package render prints search results for people and for other programs: as
+// compact lines, detailed listings, JSON, tables, CSV, and more. Renderers
+// write to any io.Writer, and only use colours and hyperlinks when asked to,
+// so that they can be used outside of a terminal.
+//
+//nolint:gochecknoglobals
+package render
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	escapes "github.com/snugfox/ansi-escapes"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+)
+
+// Options control how results are rendered.
+type Options struct {
+	// Query is the query that found the results. It's used to make the parts
+	// of each result that matched it stand out, and to describe which page of
+	// the results was shown.
+	Query nixsearch.Query
+	// Channel is the channel that the results came from, after resolving
+	// symbolic names like "stable", or "" for flakes. It's included in JSON
+	// and used to link to search.nixos.org.
+	Channel string
+	// Color enables colours and text styles.
+	Color bool
+	// Hyperlinks makes names and URLs clickable, in terminals that support
+	// them.
+	Hyperlinks bool
+	// Width is the number of columns that each line should fit in. Tables
+	// are narrowed to fit; 0 means that lines can be as long as they need
+	// to be.
+	Width int
+	// Platform is the nix system of this machine, like "x86_64-linux".
+	// Packages that can't be installed on it are dimmed, and marked as
+	// unavailable, which adds text to each line, so it should only be set
+	// when the output is for a person to read. If it's "", every package is
+	// shown as available.
+	Platform string
+	// Fields are the columns printed by the tabular formats, like "table"
+	// and "csv". See [Fields]; if it's empty, [DefaultFields] are printed.
+	Fields []string
+	// AllPages is set when the results are every page of the query, fetched
+	// with one request per page, rather than only the page that the query
+	// asks for.
+	AllPages bool
+	// Took is how long the search took, which is included by the
+	// "json-envelope" format.
+	Took time.Duration
+	// Footer adds a summary, like "showing 1-20 of 1,432 results", after the
+	// results, in the formats that are meant to be read by people.
+	Footer bool
+}
+
+// Renderer writes a page of package search results.
+type Renderer interface {
+	Render(w io.Writer, result nixsearch.SearchResult) error
+}
+
+// OptionRenderer writes NixOS options.
+type OptionRenderer interface {
+	RenderOptions(w io.Writer, options []nixsearch.Option) error
+}
+
+// formats are the formats that New accepts, other than "template=...".
+var formats = map[string]func(opts Options, fields []field) Renderer{
+	"compact":       func(opts Options, _ []field) Renderer { return compact{opts} },
+	"details":       func(opts Options, _ []field) Renderer { return details{opts} },
+	"json":          func(opts Options, _ []field) Renderer { return jsonLines{opts} },
+	"json-array":    func(opts Options, _ []field) Renderer { return jsonArray{opts} },
+	"json-envelope": func(opts Options, _ []field) Renderer { return jsonEnvelope{opts} },
+	"table":         func(opts Options, fields []field) Renderer { return table{opts, fields} },
+	"csv":           func(opts Options, fields []field) Renderer { return delimited{opts, fields, ','} },
+	"tsv":           func(opts Options, fields []field) Renderer { return delimited{opts, fields, '\t'} },
+	"markdown":      func(opts Options, fields []field) Renderer { return markdown{opts, fields} },
+	"yaml":          func(opts Options, fields []field) Renderer { return yaml{opts, fields} },
+}
+
+// tabularFormats are the formats that print the fields chosen with
+// Options.Fields.
+var tabularFormats = []string{"table", "csv", "tsv", "markdown", "yaml"}
+
+// HasFields reports whether a format prints the fields chosen with
+// Options.Fields. The others ignore them.
+func HasFields(format string) bool {
+	return slices.Contains(tabularFormats, format)
+}
+
+// Formats returns the name of every format that New accepts, for help and
+// errors.
+func Formats() []string {
+	return append(slices.Sorted(maps.Keys(formats)), "template=...")
+}
+
+// New returns the renderer for a format, one of [Formats], like "table". A
+// format of "template=" followed by a Go text/template, like
+// "template={{.AttrName}}", prints each result with the template.
+func New(format string, opts Options) (Renderer, error) {
+	if text, ok := strings.CutPrefix(format, "template="); ok {
+		return newTemplate(text, opts)
+	}
+	newRenderer, ok := formats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, expected one of: %s", format, strings.Join(Formats(), ", "))
+	}
+	fields, err := parseFields(opts.Fields)
+	if err != nil {
+		return nil, err
+	}
+	return newRenderer(opts, fields), nil
+}
+
+// NewOptionRenderer returns the renderer for options in a format, which must
+// be "compact", "details", or "json".
+func NewOptionRenderer(format string, opts Options) (OptionRenderer, error) {
+	switch format {
+	case "compact":
+		return compact{opts}, nil
+	case "details":
+		return details{opts}, nil
+	case "json":
+		return jsonLines{opts}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q for options, expected one of: compact, details, json", format)
+	}
+}
+
+// Count formats a number with thousands separators, like 120,001.
+func Count(n int) string {
+	s := strconv.Itoa(n)
+	var out strings.Builder
+	for i, r := range s {
+		if i != 0 && (len(s)-i)%3 == 0 {
+			out.WriteRune(',')
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+// footer describes which of the results were shown, like "showing 21-40 of
+// 1,432 results".
+func (o Options) footer(result nixsearch.SearchResult) string {
+	total := Count(result.Total)
+	if result.TotalIsLowerBound {
+		total = "at least " + total
+	}
+	switch {
+	case len(result.Hits) == 0 && result.Total == 0:
+		return "no results"
+	case len(result.Hits) == result.Total:
+		return fmt.Sprintf("showing all %s results", total)
+	default:
+		start := o.Query.From + 1
+		return fmt.Sprintf("showing %d-%d of %s results", start, o.Query.From+len(result.Hits), total)
+	}
+}
+
+// writeFooter writes the footer, if it was asked for.
+func (o Options) writeFooter(p *printer, result nixsearch.SearchResult) {
+	if o.Footer {
+		p.println(o.style(o.footer(result), color.Faint))
+	}
+}
+
+// style applies the attributes to the text, if colours are enabled.
+func (o Options) style(text string, attrs ...color.Attribute) string {
+	if !o.Color || len(attrs) == 0 {
+		return text
+	}
+	c := color.New(attrs...)
+	c.EnableColor()
+	return c.Sprint(text)
+}
+
+// link makes the text a hyperlink to the url, if hyperlinks are enabled.
+func (o Options) link(url, text string) string {
+	if !o.Hyperlinks || url == "" {
+		return text
+	}
+	return escapes.Link(url, text)
+}
+
+// printer writes to an io.Writer, remembering the first error so that it
+// only has to be checked once everything has been written.
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) print(a ...any) {
+	if p.err == nil {
+		_, p.err = fmt.Fprint(p.w, a...)
+	}
+}
+
+func (p *printer) printf(format string, a ...any) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, a...)
+	}
+}
+
+func (p *printer) println(a ...any) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintln(p.w, a...)
+	}
+}
+
+// firstOf returns the first non-empty string from a slice of strings, stripped
+// of all whitespace.
+func firstOf(s ...string) string {
+	for _, x := range s {
+		x = strings.TrimSpace(x)
+		if x != "" {
+			return x
+		}
+	}
+	return ""
+}
+
+// imap applies the function f to every element in the slice xs
+func imap[X any, Y any](f func(x X) Y, xs []X) []Y {
+	ys := make([]Y, 0, len(xs))
+	for _, x := range xs {
+		ys = append(ys, f(x))
+	}
+	return ys
+}
@@ -0,0 +1,400 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/peterldowns/testy/assert"
+	"github.com/peterldowns/testy/check"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+	"github.com/peterldowns/nix-search-cli/pkg/nixversion"
+)
+
+// update rewrites the golden files with the current output, instead of
+// comparing against them: go test ./pkg/render -update
+var update = flag.Bool("update", false, "update golden files") //nolint:gochecknoglobals
+
+// golden compares the output to testdata/<name>.golden.
+func golden(t *testing.T, name string, output []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		assert.Nil(t, os.WriteFile(path, output, 0o644)) //nolint:gosec // test fixtures are meant to be readable
+		return
+	}
+	expected, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	check.Equal(t, string(expected), string(output))
+}
+
+func testResult() nixsearch.SearchResult {
+	return nixsearch.SearchResult{
+		Total: 3,
+		Hits: []nixsearch.PackageHit{
+			{
+				Package: nixsearch.Package{
+					Name:        "ripgrep",
+					AttrName:    "ripgrep",
+					Version:     "14.1.1",
+					Description: "Utility that combines the usability of The Silver Searcher with the raw speed of grep",
+					Programs:    []string{"rg"},
+					Homepage:    []string{"https://github.com/BurntSushi/ripgrep"},
+					Platforms:   []string{"aarch64-linux", "x86_64-linux"},
+					Licenses: []nixsearch.License{
+						{FullName: "The Unlicense", URL: "https://unlicense.org/"},
+						{FullName: "MIT License", URL: "https://spdx.org/licenses/MIT.html"},
+					},
+					Maintainers: []nixsearch.Maintainer{{Name: "Example Person", GitHub: "example"}},
+				},
+				Score: 12.5,
+				Index: "nixos-24.11-unstable-42-abcdef",
+				Highlights: map[string][]string{
+					"package_attr_name": {"<em>rip</em>grep"},
+				},
+			},
+			{
+				Package: nixsearch.Package{
+					Name:        "ripgrep-all",
+					AttrName:    "ripgrep-all",
+					Version:     "0.10.6",
+					Description: "Ripgrep, but also search in PDFs, E-Books, Office documents, zip, tar.gz, and more",
+					Programs:    []string{"rga-preproc", "rga", "rga-fzf"},
+					Homepage:    []string{"https://github.com/phiresky/ripgrep-all", "https://example.com/rga"},
+					Platforms:   []string{"x86_64-darwin"},
+					Licenses:    []nixsearch.License{{FullName: "GNU Affero General Public License v3.0"}},
+					Teams: []nixsearch.Team{{
+						ShortName:   "Example",
+						GitHubTeams: nixsearch.OneOrMany[string]{"example"},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func testOptions() []nixsearch.Option {
+	return []nixsearch.Option{
+		{
+			Name:        "services.nginx.enable",
+			OptionType:  "boolean",
+			Default:     "false",
+			Example:     "true",
+			Description: "<p>Whether to enable &quot;nginx&quot;.</p>",
+			Source:      "nixos/modules/services/web-servers/nginx/default.nix",
+		},
+		{
+			Name:       "services.nginx.virtualHosts",
+			OptionType: "attribute set of (submodule)",
+			Default:    "{ }",
+			Example:    "{\n  \"example.org\" = { };\n}",
+			Source:     "nixos/modules/services/web-servers/nginx/default.nix",
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	plain := Options{
+		Query:   nixsearch.Query{Search: &nixsearch.MatchSearch{Search: "rga"}, MaxResults: 2},
+		Channel: "unstable",
+	}
+	styled := plain
+	styled.Color = true
+	styled.Hyperlinks = true
+	styled.Footer = true
+	styled.Platform = "x86_64-linux"
+	narrow := plain
+	narrow.Width = 60
+	narrow.Footer = true
+	timed := plain
+	timed.Took = 42 * time.Millisecond
+	fields := plain
+	fields.Fields = []string{"attr", "license", "maintainers", "platforms", "channel", "score"}
+
+	for _, tc := range []struct {
+		name   string
+		format string
+		opts   Options
+	}{
+		{"compact", "compact", plain},
+		{"compact-styled", "compact", styled},
+		{"details", "details", plain},
+		{"details-styled", "details", styled},
+		{"json", "json", plain},
+		{"json-array", "json-array", plain},
+		{"json-envelope", "json-envelope", timed},
+		{"table", "table", plain},
+		{"table-narrow", "table", narrow},
+		{"table-styled", "table", styled},
+		{"csv", "csv", fields},
+		{"tsv", "tsv", plain},
+		{"markdown", "markdown", plain},
+		{"yaml", "yaml", fields},
+		{"template", "template={{.AttrName}}\t{{join .Programs \",\"}}\t{{.Channel}}", plain},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			renderer, err := New(tc.format, tc.opts)
+			assert.Nil(t, err)
+			var out bytes.Buffer
+			assert.Nil(t, renderer.Render(&out, testResult()))
+			golden(t, tc.name, out.Bytes())
+		})
+	}
+}
+
+func TestRenderOptions(t *testing.T) {
+	t.Parallel()
+
+	plain := Options{Channel: "24.11"}
+	styled := plain
+	styled.Color = true
+	styled.Hyperlinks = true
+
+	for _, tc := range []struct {
+		name   string
+		format string
+		opts   Options
+	}{
+		{"options-compact", "compact", plain},
+		{"options-details", "details", plain},
+		{"options-details-styled", "details", styled},
+		{"options-json", "json", plain},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			renderer, err := NewOptionRenderer(tc.format, tc.opts)
+			assert.Nil(t, err)
+			var out bytes.Buffer
+			assert.Nil(t, renderer.RenderOptions(&out, testOptions()))
+			golden(t, tc.name, out.Bytes())
+		})
+	}
+}
+
+func TestRenderNoResults(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	renderer, err := New("compact", Options{Footer: true})
+	assert.Nil(t, err)
+	assert.Nil(t, renderer.Render(&out, nixsearch.SearchResult{}))
+	check.Equal(t, "no results\n", out.String())
+
+	out.Reset()
+	renderer, err = New("yaml", Options{})
+	assert.Nil(t, err)
+	assert.Nil(t, renderer.Render(&out, nixsearch.SearchResult{}))
+	check.Equal(t, "[]\n", out.String())
+
+	out.Reset()
+	renderer, err = New("json-array", Options{})
+	assert.Nil(t, err)
+	assert.Nil(t, renderer.Render(&out, nixsearch.SearchResult{}))
+	check.Equal(t, "[]\n", out.String())
+
+	// Scripts can tell that there weren't any results, rather than that
+	// nothing was printed.
+	out.Reset()
+	renderer, err = New("json-envelope", Options{Channel: "unstable", Query: nixsearch.Query{Channel: "unstable"}})
+	assert.Nil(t, err)
+	assert.Nil(t, renderer.Render(&out, nixsearch.SearchResult{}))
+	var envelope map[string]any
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &envelope))
+	check.Equal[any](t, []any{}, envelope["results"])
+	check.Equal[any](t, 0.0, envelope["total"])
+	check.Equal[any](t, "nixos-unstable", envelope["index"])
+}
+
+func TestEnvelopeRequest(t *testing.T) {
+	t.Parallel()
+
+	request := func(opts Options) any {
+		t.Helper()
+		renderer, err := New("json-envelope", opts)
+		assert.Nil(t, err)
+		var out bytes.Buffer
+		assert.Nil(t, renderer.Render(&out, testResult()))
+		var envelope struct {
+			Query map[string]any `json:"query"`
+		}
+		assert.Nil(t, json.Unmarshal(out.Bytes(), &envelope))
+		return envelope.Query["request"]
+	}
+	query := nixsearch.Query{Channel: "unstable", MaxResults: 20, Name: &nixsearch.MatchName{Name: "ripgrep"}}
+	check.NotEqual(t, nil, request(Options{Query: query}))
+
+	// The results of more than one request aren't described by any one
+	// of them.
+	check.Equal(t, nil, request(Options{Query: query, AllPages: true}))
+	constraint, err := nixversion.ParseConstraint(">=14")
+	assert.Nil(t, err)
+	query.VersionRange = &constraint
+	check.Equal(t, nil, request(Options{Query: query}))
+}
+
+func TestNewErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := New("bogus", Options{})
+	check.Error(t, err)
+	_, err = New("table", Options{Fields: []string{"attr", "bogus"}})
+	check.Error(t, err)
+	_, err = New("template={{", Options{})
+	check.Error(t, err)
+	_, err = NewOptionRenderer("table", Options{})
+	check.Error(t, err)
+}
+
+// failingWriter fails every write, to check that renderers return errors
+// instead of dropping them.
+type failingWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errWrite }
+
+func TestRenderWriteError(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range Formats() {
+		if format == "template=..." {
+			format = "template={{.AttrName}}"
+		}
+		renderer, err := New(format, Options{})
+		assert.Nil(t, err)
+		check.True(t, errors.Is(renderer.Render(failingWriter{}, testResult()), errWrite))
+	}
+}
+
+func TestCount(t *testing.T) {
+	t.Parallel()
+
+	check.Equal(t, "0", Count(0))
+	check.Equal(t, "999", Count(999))
+	check.Equal(t, "1,000", Count(1000))
+	check.Equal(t, "120,001", Count(120001))
+}
+
+func TestRenderTrimsDescription(t *testing.T) {
+	t.Parallel()
+
+	result := nixsearch.SearchResult{Hits: []nixsearch.PackageHit{{
+		Package: nixsearch.Package{AttrName: "hello", Description: "\n  Says hello\n\n"},
+		Highlights: map[string][]string{
+			"package_description": {"\n  Says <em>hello</em>\n\n"},
+		},
+	}}}
+	renderer, err := New("details", Options{})
+	assert.Nil(t, err)
+	var out bytes.Buffer
+	assert.Nil(t, renderer.Render(&out, result))
+	check.True(t, bytes.Contains(out.Bytes(), []byte("  description: Says hello\n  ")))
+
+	check.Equal(t, []nixsearch.Span{{Text: "Says "}, {Text: "hello", Matched: true}}, trimSpans([]nixsearch.Span{
+		{Text: "\n "}, {Text: "Says "}, {Text: "hello", Matched: true}, {Text: "\n"},
+	}))
+}
+
+func TestHasFields(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []string{"table", "csv", "tsv", "markdown", "yaml"} {
+		check.True(t, HasFields(format))
+	}
+	for _, format := range []string{"compact", "details", "json", "json-array", "json-envelope", "template={{.AttrName}}"} {
+		check.False(t, HasFields(format))
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	t.Parallel()
+
+	check.Equal(t, "ripgrep", truncateText("ripgrep", 7))
+	check.Equal(t, "ripgrep", truncateText("ripgrep", 20))
+	check.Equal(t, "ripg…", truncateText("ripgrep", 5))
+	check.Equal(t, "…", truncateText("ripgrep", 1))
+	check.Equal(t, "", truncateText("ripgrep", 0))
+	// Width is counted in characters, not bytes.
+	check.Equal(t, "héll…", truncateText("héllo wörld", 5))
+}
+
+func TestFitColumns(t *testing.T) {
+	t.Parallel()
+
+	// Columns that already fit are left alone.
+	widths := []int{10, 20}
+	fitColumns(widths, 40)
+	check.Equal(t, []int{10, 20}, widths)
+
+	// The widest column is narrowed first, to exactly fill the width.
+	widths = []int{10, 50, 30}
+	fitColumns(widths, 60)
+	check.Equal(t, []int{10, 16, 30}, widths)
+	check.Equal(t, 60, 10+16+30+2*2)
+
+	// Then the next widest, until each is at the minimum width.
+	widths = []int{12, 50, 30}
+	fitColumns(widths, 30)
+	check.Equal(t, []int{minColumnWidth, minColumnWidth, minColumnWidth}, widths)
+}
+
+func TestYAMLString(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"ripgrep", "ripgrep"},
+		{"python3Packages.requests", "python3Packages.requests"},
+		{"x86_64-linux", "x86_64-linux"},
+		{"14.1.1", `"14.1.1"`},
+		{"1", `"1"`},
+		{"yes", `"yes"`},
+		{"Null", `"Null"`},
+		{"~", `"~"`},
+		{"", `""`},
+		{"MIT License", `"MIT License"`},
+		{"key: value", `"key: value"`},
+		{"-dash", `"-dash"`},
+		{`say "hi"`, `"say \"hi\""`},
+	} {
+		check.Equal(t, tc.want, yamlString(tc.in))
+	}
+}
+
+func TestDelimitedQuoting(t *testing.T) {
+	t.Parallel()
+
+	result := nixsearch.SearchResult{Hits: []nixsearch.PackageHit{{
+		Package: nixsearch.Package{AttrName: "hello", Description: "Says \"hello\", then exits", Programs: []string{"hello", "hi"}},
+	}}}
+	opts := Options{Fields: []string{"attr", "programs", "description"}}
+
+	csvRenderer, err := New("csv", opts)
+	assert.Nil(t, err)
+	var out bytes.Buffer
+	assert.Nil(t, csvRenderer.Render(&out, result))
+	check.Equal(t, "attr,programs,description\nhello,hello hi,\"Says \"\"hello\"\", then exits\"\n", out.String())
+
+	tsvRenderer, err := New("tsv", opts)
+	assert.Nil(t, err)
+	out.Reset()
+	assert.Nil(t, tsvRenderer.Render(&out, result))
+	check.Equal(t, "attr\tprograms\tdescription\nhello\thello hi\t\"Says \"\"hello\"\", then exits\"\n", out.String())
+
+	markdownRenderer, err := New("markdown", Options{Fields: []string{"attr", "description"}})
+	assert.Nil(t, err)
+	out.Reset()
+	result.Hits[0].Description = "a | b\nc"
+	assert.Nil(t, markdownRenderer.Render(&out, result))
+	check.Equal(t, "| attr | description |\n| --- | --- |\n| hello | a \\| b c |\n", out.String())
+}
@@ -0,0 +1,335 @@
+//nolint:gochecknoglobals
+package render
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+)
+
+// field is a column that the tabular formats can print.
+type field struct {
+	name string
+	// multi is set for fields that can have any number of values, like
+	// programs, as opposed to a single value, like version.
+	multi bool
+	// sep joins the values of multi fields in tabular formats.
+	sep    string
+	values func(o Options, hit nixsearch.PackageHit) []string
+}
+
+// single makes the values func of a field with a single value.
+func single(value func(o Options, hit nixsearch.PackageHit) string) func(Options, nixsearch.PackageHit) []string {
+	return func(o Options, hit nixsearch.PackageHit) []string {
+		return []string{value(o, hit)}
+	}
+}
+
+// fields are the fields that Options.Fields can choose from, in the order
+// they're listed in errors.
+var fields = []field{
+	{name: "attr", values: single(func(_ Options, hit nixsearch.PackageHit) string { return hit.ID() })},
+	{name: "name", values: single(func(_ Options, hit nixsearch.PackageHit) string { return hit.Name })},
+	{name: "version", values: single(func(_ Options, hit nixsearch.PackageHit) string { return hit.Version })},
+	{name: "description", values: single(func(_ Options, hit nixsearch.PackageHit) string {
+		return strings.Join(strings.Fields(firstOf(hit.FlakeDescription, hit.Description)), " ")
+	})},
+	{name: "programs", multi: true, sep: " ", values: func(_ Options, hit nixsearch.PackageHit) []string {
+		return hit.Programs
+	}},
+	{name: "license", multi: true, sep: ", ", values: func(_ Options, hit nixsearch.PackageHit) []string {
+		return imap(func(l nixsearch.License) string { return firstOf(l.SPDXID(), l.FullName) }, hit.Licenses)
+	}},
+	{name: "maintainers", multi: true, sep: ", ", values: func(_ Options, hit nixsearch.PackageHit) []string {
+		return imap(func(m nixsearch.Maintainer) string {
+			if m.GitHub != "" {
+				return "@" + m.GitHub
+			}
+			return firstOf(m.Name, m.Email)
+		}, hit.Maintainers)
+	}},
+	{name: "platforms", multi: true, sep: " ", values: func(_ Options, hit nixsearch.PackageHit) []string {
+		return hit.Platforms
+	}},
+	{name: "homepage", multi: true, sep: " ", values: func(_ Options, hit nixsearch.PackageHit) []string {
+		return hit.Homepage
+	}},
+	{name: "channel", values: single(func(o Options, _ nixsearch.PackageHit) string { return o.Channel })},
+	{name: "score", values: single(func(_ Options, hit nixsearch.PackageHit) string {
+		if hit.Score == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(hit.Score, 'f', 2, 64)
+	})},
+	{name: "index", values: single(func(_ Options, hit nixsearch.PackageHit) string { return hit.Index })},
+}
+
+// DefaultFields are the fields printed by the tabular formats, unless
+// Options.Fields says otherwise.
+var DefaultFields = []string{"attr", "version", "programs", "description"}
+
+// Fields returns the name of every field that Options.Fields can choose from.
+func Fields() []string {
+	return fieldNames(fields)
+}
+
+// parseFields returns the fields with the given names.
+func parseFields(names []string) ([]field, error) {
+	if len(names) == 0 {
+		names = DefaultFields
+	}
+	chosen := make([]field, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		i := slices.IndexFunc(fields, func(f field) bool { return f.name == name })
+		if i == -1 {
+			return nil, fmt.Errorf("unknown field %q, expected any of: %s", name, strings.Join(Fields(), ", "))
+		}
+		chosen = append(chosen, fields[i])
+	}
+	return chosen, nil
+}
+
+// text returns the values of the field joined into a single cell.
+func (f field) text(o Options, hit nixsearch.PackageHit) string {
+	return strings.Join(f.values(o, hit), f.sep)
+}
+
+// rows returns the cells of every hit, one row per hit.
+func rows(o Options, fields []field, hits []nixsearch.PackageHit) [][]string {
+	out := make([][]string, 0, len(hits))
+	for _, hit := range hits {
+		out = append(out, imap(func(f field) string { return f.text(o, hit) }, fields))
+	}
+	return out
+}
+
+func fieldNames(fields []field) []string {
+	return imap(func(f field) string { return f.name }, fields)
+}
+
+// table prints the fields in aligned columns. If Options.Width is set, the
+// widest columns are cut short so that each row fits on one line.
+type table struct {
+	Options
+	fields []field
+}
+
+func (r table) Render(w io.Writer, result nixsearch.SearchResult) error {
+	p := &printer{w: w}
+	header := fieldNames(r.fields)
+	body := rows(r.Options, r.fields, result.Hits)
+	widths := imap(utf8.RuneCountInString, header)
+	for _, row := range body {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	if r.Width > 0 {
+		fitColumns(widths, r.Width)
+	}
+	for n, row := range slices.Concat([][]string{header}, body) {
+		for i, cell := range row {
+			cell = truncateText(cell, widths[i])
+			if i != len(row)-1 {
+				cell += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+			}
+			if n == 0 {
+				cell = r.style(cell, color.Bold)
+			}
+			if i != 0 {
+				p.print("  ")
+			}
+			p.print(cell)
+		}
+		p.print("\n")
+	}
+	r.writeFooter(p, result)
+	return p.err
+}
+
+// minColumnWidth is the narrowest that a table cuts a column down to.
+const minColumnWidth = 10
+
+// fitColumns narrows the widest columns until a row, with two spaces between
+// each column, fits within the width, or every column is as narrow as it
+// can get.
+func fitColumns(widths []int, width int) {
+	total := func() int {
+		sum := 2 * (len(widths) - 1)
+		for _, w := range widths {
+			sum += w
+		}
+		return sum
+	}
+	for total() > width {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minColumnWidth {
+			return
+		}
+		widths[widest] = max(minColumnWidth, widths[widest]-(total()-width))
+	}
+}
+
+// truncateText cuts text down to width characters, ending with "…" if
+// anything was cut.
+func truncateText(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	if width < 1 {
+		return ""
+	}
+	runes := []rune(text)
+	return string(runes[:width-1]) + "…"
+}
+
+// delimited prints the fields as CSV or, with a tab as the separator, TSV,
+// with a header row.
+type delimited struct {
+	Options
+	fields []field
+	sep    rune
+}
+
+func (r delimited) Render(w io.Writer, result nixsearch.SearchResult) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = r.sep
+	if err := cw.Write(fieldNames(r.fields)); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows(r.Options, r.fields, result.Hits)); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// markdown prints the fields as a Markdown table, ready to paste into an
+// issue or PR description.
+type markdown struct {
+	Options
+	fields []field
+}
+
+func (r markdown) Render(w io.Writer, result nixsearch.SearchResult) error {
+	p := &printer{w: w}
+	escape := strings.NewReplacer("|", `\|`, "\n", " ")
+	printRow := func(cells []string) {
+		p.printf("| %s |\n", strings.Join(imap(escape.Replace, cells), " | "))
+	}
+	printRow(fieldNames(r.fields))
+	printRow(imap(func(field) string { return "---" }, r.fields))
+	for _, row := range rows(r.Options, r.fields, result.Hits) {
+		printRow(row)
+	}
+	return p.err
+}
+
+// yaml prints the fields of each result as a YAML list of mappings. Fields
+// with any number of values are lists.
+type yaml struct {
+	Options
+	fields []field
+}
+
+func (r yaml) Render(w io.Writer, result nixsearch.SearchResult) error {
+	p := &printer{w: w}
+	if len(result.Hits) == 0 {
+		p.println("[]")
+		return p.err
+	}
+	for _, hit := range result.Hits {
+		for i, f := range r.fields {
+			prefix := "  "
+			if i == 0 {
+				prefix = "- "
+			}
+			values := f.values(r.Options, hit)
+			switch {
+			case !f.multi:
+				p.printf("%s%s: %s\n", prefix, f.name, yamlString(firstOf(values...)))
+			case len(values) == 0:
+				p.printf("%s%s: []\n", prefix, f.name)
+			default:
+				p.printf("%s%s:\n", prefix, f.name)
+				for _, value := range values {
+					p.printf("    - %s\n", yamlString(value))
+				}
+			}
+		}
+	}
+	return p.err
+}
+
+// yamlPlain matches strings that can be written in YAML without quotes and
+// are still read back as the same string, rather than as a number, a bool,
+// or anything else.
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_./+@-]*$`)
+
+// yamlString formats a string as a YAML scalar, quoting it if it needs to be.
+func yamlString(s string) string {
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~":
+		return strconv.Quote(s)
+	}
+	if yamlPlain.MatchString(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// templateFuncs are the functions that templates can use, in addition to the
+// builtin ones.
+var templateFuncs = template.FuncMap{
+	// {{join .Programs ", "}}
+	"join": func(values []string, sep string) string { return strings.Join(values, sep) },
+}
+
+// tmpl prints each result with a Go text/template, followed by a newline if
+// the template doesn't end with one. The template is executed with a
+// [Package]: every field of the package, like {{.AttrName}} and
+// {{.Version}}, its {{.Score}} and {{.Index}}, and the {{.Channel}} it was
+// found in.
+type tmpl struct {
+	Options
+	template *template.Template
+}
+
+func newTemplate(text string, opts Options) (Renderer, error) {
+	t, err := template.New("format").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return tmpl{opts, t}, nil
+}
+
+func (r tmpl) Render(w io.Writer, result nixsearch.SearchResult) error {
+	for _, hit := range result.Hits {
+		var out strings.Builder
+		if err := r.template.Execute(&out, Package{PackageHit: hit, Channel: r.Channel}); err != nil {
+			return err
+		}
+		if !strings.HasSuffix(out.String(), "\n") {
+			out.WriteString("\n")
+		}
+		if _, err := io.WriteString(w, out.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
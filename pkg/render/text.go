@@ -0,0 +1,376 @@
+//nolint:gochecknoglobals
+package render
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/fatih/color"
+
+	"github.com/peterldowns/nix-search-cli/pkg/nixsearch"
+)
+
+// compact prints each result on one line, with its version and programs.
+type compact struct{ Options }
+
+func (r compact) Render(w io.Writer, result nixsearch.SearchResult) error {
+	p := &printer{w: w}
+	for _, hit := range result.Hits {
+		pkg := hit.Package
+		// name @ version: program1 program2 ...
+		p.print(r.name(hit))
+		if pkg.Version != "" {
+			p.print(" ", r.version("@ ")+r.packageVersion(hit))
+		}
+		if len(pkg.Programs) > 0 {
+			p.print(" : ", r.programs(hit))
+		}
+		if unavailable := r.unavailable(pkg); unavailable != "" {
+			p.print(" ", unavailable)
+		}
+		p.print("\n")
+	}
+	r.writeFooter(p, result)
+	return p.err
+}
+
+func (r compact) RenderOptions(w io.Writer, options []nixsearch.Option) error {
+	p := &printer{w: w}
+	for _, option := range options {
+		// services.example.enable : boolean
+		p.print(r.optionName(option))
+		if option.OptionType != "" {
+			p.print(" : ", r.version(option.OptionType))
+		}
+		p.print("\n")
+	}
+	return p.err
+}
+
+// details prints every field of each result, over multiple lines.
+type details struct{ Options }
+
+func (r details) Render(w io.Writer, result nixsearch.SearchResult) error {
+	p := &printer{w: w}
+	for _, hit := range result.Hits {
+		pkg := hit.Package
+		// examplePkg
+		//  version: 3.1
+		//  programs: hello goodbye true false
+		//  description: examplePkg is a made up package as an example.
+		//  platforms: x86_64-linux aarch64-linux
+		//  homepage: [https://example.com]
+		//    - https://example.com/one
+		//    - https://example.org/two
+		//  license: [Single License Result Shown On One Line]
+		//    - Multiple License
+		//    - Results Shown Over Multiple Lines
+		//  maintainers: [Example Person (@example)]
+		//  teams: [Example Team (@NixOS/example)]
+		//  score: 12.34 (in nixos-43-unstable-...)
+		p.print(r.name(hit))
+		if unavailable := r.unavailable(pkg); unavailable != "" {
+			p.print(" ", unavailable)
+		}
+		p.print("\n")
+		p.printf("  version: %s\n", r.packageVersion(hit))
+		p.printf("  programs: %s\n", r.programs(hit))
+		p.printf("  description: %s\n", r.description(hit))
+		p.printf("  platforms: %s\n", r.platforms(pkg.Platforms))
+		p.print("  homepage:", list(imap(func(s string) string {
+			return r.linkText(s, s, color.Underline)
+		}, pkg.Homepage)))
+		p.print("  license:", list(imap(r.license, pkg.Licenses)))
+		p.print("  maintainers:", list(imap(r.maintainer, pkg.Maintainers)))
+		p.print("  teams:", list(imap(r.team, pkg.Teams)))
+		if hit.Score != 0 || hit.Index != "" {
+			p.printf("  score: %s\n", r.score(hit))
+		}
+	}
+	r.writeFooter(p, result)
+	return p.err
+}
+
+func (r details) RenderOptions(w io.Writer, options []nixsearch.Option) error {
+	p := &printer{w: w}
+	for _, option := range options {
+		// services.example.enable
+		//  type: boolean
+		//  default: false
+		//  example: true
+		//  description: Whether to enable the example service.
+		//  declared in: nixos/modules/services/example.nix
+		p.print(r.optionName(option), "\n")
+		p.printf("  type: %s\n", r.version(option.OptionType))
+		p.printf("  default:%s", nixValue(option.Default))
+		p.printf("  example:%s", nixValue(option.Example))
+		p.printf("  description: %s\n", optionDescription(option))
+		p.printf("  declared in: %s\n", r.optionSource(option))
+	}
+	return p.err
+}
+
+func (o Options) score(hit nixsearch.PackageHit) string {
+	score := strconv.FormatFloat(hit.Score, 'f', 2, 64)
+	if hit.Index != "" {
+		score += o.style(fmt.Sprintf(" (in %s)", hit.Index), color.Faint)
+	}
+	return score
+}
+
+func (o Options) name(hit nixsearch.PackageHit) string {
+	pkg := hit.Package
+	if pkg.IsFlake() {
+		url := fmt.Sprintf(
+			`https://search.nixos.org/flakes?show=%s&query=%s`,
+			pkg.AttrName,
+			pkg.AttrName,
+		)
+		return o.linkText(url, pkg.ID(), color.Underline, color.FgWhite)
+	}
+	url := fmt.Sprintf(`https://search.nixos.org/packages?channel=%s&show=%s`, o.Channel, pkg.AttrName)
+	spans := hit.Highlight("package_attr_name", pkg.AttrName)
+	if !o.availableOn(pkg) {
+		// Dim packages that can't be installed on this machine
+		return o.highlightedLink(url, spans, color.Faint, color.FgBlue)
+	}
+	return o.highlightedLink(url, spans)
+}
+
+// availableOn reports whether the package can be installed on this machine,
+// which is assumed if its platform isn't known.
+func (o Options) availableOn(pkg nixsearch.Package) bool {
+	return o.Platform == "" || pkg.AvailableOn(o.Platform)
+}
+
+// unavailable returns a warning if the package can't be installed on this
+// machine, otherwise "".
+func (o Options) unavailable(pkg nixsearch.Package) string {
+	if o.availableOn(pkg) {
+		return ""
+	}
+	return o.style(fmt.Sprintf("(not available on %s)", o.Platform), color.FgYellow, color.Faint)
+}
+
+func (o Options) platforms(platforms []string) string {
+	if len(platforms) == 0 {
+		return ""
+	}
+	if o.Color {
+		// Highlight this machine's platform, if it's one of them
+		formatted := make([]string, 0, len(platforms))
+		for _, platform := range platforms {
+			if platform == o.Platform {
+				formatted = append(formatted, o.style(platform, color.Bold))
+			} else {
+				formatted = append(formatted, o.style(platform, color.Faint))
+			}
+		}
+		platforms = formatted
+	}
+	return strings.Join(platforms, " ")
+}
+
+func (o Options) version(version string) string {
+	return o.style(version, color.FgGreen, color.Faint)
+}
+
+// packageVersion is version for a package's version, making any part of it
+// that matched the query bold.
+func (o Options) packageVersion(hit nixsearch.PackageHit) string {
+	spans := hit.Highlight("package_pversion", hit.Version)
+	return o.spans(spans, color.FgGreen, color.Faint)
+}
+
+func (o Options) programs(hit nixsearch.PackageHit) string {
+	programs := hit.Programs
+	if len(programs) == 0 {
+		return ""
+	}
+	if o.Color {
+		programs = slices.Sorted(slices.Values(programs))
+		var matches []string
+		var others []string
+		// Dim all the programs that aren't what you searched for, and make
+		// the parts of them that matched bold
+		for _, program := range programs {
+			switch {
+			case o.Query.ExactlyMatches(program):
+				matches = append(matches, o.style(program, color.Bold))
+			case hit.IsHighlighted("package_programs", program):
+				matches = append(matches, o.spans(hit.Highlight("package_programs", program)))
+			default:
+				others = append(others, o.style(program, color.Faint))
+			}
+		}
+		programs = append(matches, others...)
+	}
+	return strings.Join(programs, " ")
+}
+
+func (o Options) description(hit nixsearch.PackageHit) string {
+	if description := strings.TrimSpace(hit.FlakeDescription); description != "" {
+		return description
+	}
+	return o.spans(trimSpans(hit.Highlight("package_description", hit.Description)))
+}
+
+// trimSpans removes any whitespace from the start of the first span and the
+// end of the last, like [strings.TrimSpace].
+func trimSpans(spans []nixsearch.Span) []nixsearch.Span {
+	spans = slices.Clone(spans)
+	for len(spans) != 0 {
+		spans[0].Text = strings.TrimLeftFunc(spans[0].Text, unicode.IsSpace)
+		if spans[0].Text != "" {
+			break
+		}
+		spans = spans[1:]
+	}
+	for len(spans) != 0 {
+		last := len(spans) - 1
+		spans[last].Text = strings.TrimRightFunc(spans[last].Text, unicode.IsSpace)
+		if spans[last].Text != "" {
+			break
+		}
+		spans = spans[:last]
+	}
+	return spans
+}
+
+// spans styles text with attrs, and makes the spans of it that matched the
+// query bold.
+func (o Options) spans(spans []nixsearch.Span, attrs ...color.Attribute) string {
+	var out strings.Builder
+	for _, span := range spans {
+		if span.Matched {
+			out.WriteString(o.style(span.Text, append(slices.Clip(attrs), color.Bold)...))
+		} else {
+			out.WriteString(o.style(span.Text, attrs...))
+		}
+	}
+	return out.String()
+}
+
+func (o Options) license(license nixsearch.License) string {
+	return o.linkText(license.URL, license.FullName, color.Underline)
+}
+
+// maintainer formats a maintainer as "Name (@github)", linking to their
+// GitHub profile.
+func (o Options) maintainer(maintainer nixsearch.Maintainer) string {
+	if maintainer.GitHub == "" {
+		return firstOf(maintainer.Name, maintainer.Email)
+	}
+	text := "@" + maintainer.GitHub
+	if maintainer.Name != "" {
+		text = fmt.Sprintf("%s (@%s)", maintainer.Name, maintainer.GitHub)
+	}
+	return o.linkText("https://github.com/"+maintainer.GitHub, text, color.Underline)
+}
+
+// team formats a team as "ShortName (@NixOS/team)", linking to the team on
+// GitHub.
+func (o Options) team(team nixsearch.Team) string {
+	if len(team.GitHubTeams) == 0 {
+		return team.ShortName
+	}
+	text := fmt.Sprintf("%s (@NixOS/%s)", team.ShortName, team.GitHubTeams[0])
+	url := "https://github.com/orgs/NixOS/teams/" + team.GitHubTeams[0]
+	return o.linkText(url, text, color.Underline)
+}
+
+func (o Options) linkText(url, text string, attrs ...color.Attribute) string {
+	return o.highlightedLink(url, []nixsearch.Span{{Text: text}}, attrs...)
+}
+
+// highlightedLink is linkText for text in which the spans that matched the
+// query are made bold.
+func (o Options) highlightedLink(url string, spans []nixsearch.Span, attrs ...color.Attribute) string {
+	if url == "" {
+		return o.spans(spans)
+	}
+	if attrs == nil {
+		// Default styling
+		attrs = []color.Attribute{color.Underline, color.FgBlue}
+	}
+	return o.link(url, o.spans(spans, attrs...))
+}
+
+// list formats the values of a field in the details format: a single value
+// on the same line as the field's name, or several values on the lines
+// after it.
+func list(data []string) string {
+	if len(data) == 0 {
+		return "\n"
+	}
+	if len(data) == 1 {
+		return fmt.Sprintf(" %s\n", data[0])
+	}
+	out := strings.Builder{}
+	out.WriteString("\n")
+	for _, s := range data {
+		out.WriteString(fmt.Sprintf("    - %s\n", s))
+	}
+	return out.String()
+}
+
+func (o Options) optionName(option nixsearch.Option) string {
+	if option.IsFlake() {
+		url := fmt.Sprintf(
+			`https://search.nixos.org/flakes?type=options&show=%s&query=%s`,
+			option.Name,
+			option.Name,
+		)
+		return o.linkText(url, option.Name, color.Underline, color.FgWhite)
+	}
+	url := fmt.Sprintf(`https://search.nixos.org/options?channel=%s&show=%s`, o.Channel, option.Name)
+	return o.linkText(url, option.Name)
+}
+
+// nixValue formats a default or example value, which are Nix expressions and
+// often span multiple lines.
+func nixValue(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "\n"
+	}
+	lines := strings.Split(value, "\n")
+	if len(lines) == 1 {
+		return fmt.Sprintf(" %s\n", value)
+	}
+	out := strings.Builder{}
+	out.WriteString("\n")
+	for _, line := range lines {
+		out.WriteString(fmt.Sprintf("    %s\n", line))
+	}
+	return out.String()
+}
+
+func optionDescription(option nixsearch.Option) string {
+	description := firstOf(option.Description, option.FlakeDescription)
+	// Descriptions are rendered to HTML by the indexer.
+	description = html.UnescapeString(htmlTag.ReplaceAllString(description, ""))
+	return strings.Join(strings.Fields(description), " ")
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// optionSource links to the file that declares an option, in the nixpkgs
+// branch of the channel that was searched.
+func (o Options) optionSource(option nixsearch.Option) string {
+	if option.Source == "" || option.IsFlake() {
+		return option.Source
+	}
+	url := fmt.Sprintf(
+		`https://github.com/NixOS/nixpkgs/blob/nixos-%s/%s`,
+		o.Channel,
+		option.Source,
+	)
+	return o.linkText(url, option.Source, color.Underline)
+}